@@ -0,0 +1,130 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto copies the receiver into out
+func (in *SourceRangeSet) DeepCopyInto(out *SourceRangeSet) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy copies the receiver into a new SourceRangeSet
+func (in *SourceRangeSet) DeepCopy() *SourceRangeSet {
+	if in == nil {
+		return nil
+	}
+	out := new(SourceRangeSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject copies the receiver into a new runtime.Object
+func (in *SourceRangeSet) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto copies the receiver into out
+func (in *SourceRangeSetList) DeepCopyInto(out *SourceRangeSetList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		out.Items = make([]SourceRangeSet, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+// DeepCopy copies the receiver into a new SourceRangeSetList
+func (in *SourceRangeSetList) DeepCopy() *SourceRangeSetList {
+	if in == nil {
+		return nil
+	}
+	out := new(SourceRangeSetList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject copies the receiver into a new runtime.Object
+func (in *SourceRangeSetList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto copies the receiver into out
+func (in *ClusterSourceRangeSet) DeepCopyInto(out *ClusterSourceRangeSet) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy copies the receiver into a new ClusterSourceRangeSet
+func (in *ClusterSourceRangeSet) DeepCopy() *ClusterSourceRangeSet {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterSourceRangeSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject copies the receiver into a new runtime.Object
+func (in *ClusterSourceRangeSet) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto copies the receiver into out
+func (in *ClusterSourceRangeSetList) DeepCopyInto(out *ClusterSourceRangeSetList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		out.Items = make([]ClusterSourceRangeSet, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+// DeepCopy copies the receiver into a new ClusterSourceRangeSetList
+func (in *ClusterSourceRangeSetList) DeepCopy() *ClusterSourceRangeSetList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterSourceRangeSetList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject copies the receiver into a new runtime.Object
+func (in *ClusterSourceRangeSetList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto copies the receiver into out
+func (in *SourceRangeSetSpec) DeepCopyInto(out *SourceRangeSetSpec) {
+	*out = *in
+	if in.SourceRanges != nil {
+		out.SourceRanges = make([]SourceRange, len(in.SourceRanges))
+		copy(out.SourceRanges, in.SourceRanges)
+	}
+}
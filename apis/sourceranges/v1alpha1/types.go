@@ -0,0 +1,77 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jeffersongirao/source-ranges-controller/cidr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SourceRangeSet is a namespaced set of source ranges Services in the same namespace can reference
+type SourceRangeSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SourceRangeSetSpec   `json:"spec"`
+	Status SourceRangeSetStatus `json:"status,omitempty"`
+}
+
+// SourceRangeSetList is a list of SourceRangeSets
+type SourceRangeSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []SourceRangeSet `json:"items"`
+}
+
+// ClusterSourceRangeSet is a cluster-wide set of source ranges Services in any namespace can reference
+type ClusterSourceRangeSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SourceRangeSetSpec   `json:"spec"`
+	Status SourceRangeSetStatus `json:"status,omitempty"`
+}
+
+// ClusterSourceRangeSetList is a list of ClusterSourceRangeSets
+type ClusterSourceRangeSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ClusterSourceRangeSet `json:"items"`
+}
+
+// SourceRangeSetSpec is the desired list of source ranges
+type SourceRangeSetSpec struct {
+	SourceRanges []SourceRange `json:"sourceRanges"`
+}
+
+// SourceRange is a CIDR with an optional description of what it is
+type SourceRange struct {
+	CIDR        string `json:"cidr"`
+	Description string `json:"description,omitempty"`
+}
+
+// SourceRangeSetStatus is the observed state of a set of source ranges
+type SourceRangeSetStatus struct {
+	// Consumers is the number of Services referencing the set
+	Consumers int `json:"consumers"`
+	// LastValidationError describes the invalid source ranges of the set, if any
+	LastValidationError string `json:"lastValidationError,omitempty"`
+}
+
+// Validate returns an error describing every source range that isn't a valid CIDR or IP address
+func (s SourceRangeSetSpec) Validate() error {
+	var invalid []string
+	for _, sourceRange := range s.SourceRanges {
+		if _, err := cidr.Parse(sourceRange.CIDR); err != nil {
+			invalid = append(invalid, fmt.Sprintf("%q", sourceRange.CIDR))
+		}
+	}
+
+	if len(invalid) != 0 {
+		return fmt.Errorf("invalid CIDR %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
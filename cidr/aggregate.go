@@ -0,0 +1,107 @@
+package cidr
+
+import (
+	"math/big"
+	"net"
+	"sort"
+)
+
+type interval struct {
+	start *big.Int
+	end   *big.Int
+}
+
+var one = big.NewInt(1)
+
+// Aggregate merges adjacent and overlapping source ranges into the smallest list of CIDRs
+// covering exactly the same addresses, it never allows an address that wasn't allowed before.
+// Values that can't be parsed are kept as they are at the end of the list.
+func Aggregate(values []string) []string {
+	var v4, v6 []interval
+	var invalid []string
+
+	for _, value := range values {
+		ipNet, err := Parse(value)
+		if err != nil {
+			invalid = append(invalid, value)
+			continue
+		}
+
+		ones, bits := ipNet.Mask.Size()
+		ip := ipNet.IP.To16()
+		if bits == 32 {
+			ip = ipNet.IP.To4()
+		}
+
+		start := new(big.Int).SetBytes(ip)
+		end := new(big.Int).Lsh(one, uint(bits-ones))
+		end.Add(end, start).Sub(end, one)
+
+		if bits == 32 {
+			v4 = append(v4, interval{start: start, end: end})
+		} else {
+			v6 = append(v6, interval{start: start, end: end})
+		}
+	}
+
+	var aggregated []string
+	for _, i := range merge(v4) {
+		aggregated = append(aggregated, i.cidrs(32)...)
+	}
+	for _, i := range merge(v6) {
+		aggregated = append(aggregated, i.cidrs(128)...)
+	}
+	return append(aggregated, invalid...)
+}
+
+// merge returns the sorted union of the intervals joining the adjacent ones
+func merge(intervals []interval) []interval {
+	if len(intervals) == 0 {
+		return nil
+	}
+
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].start.Cmp(intervals[j].start) < 0
+	})
+
+	merged := []interval{intervals[0]}
+	for _, i := range intervals[1:] {
+		last := &merged[len(merged)-1]
+		next := new(big.Int).Add(last.end, one)
+		if i.start.Cmp(next) > 0 {
+			merged = append(merged, i)
+			continue
+		}
+		if i.end.Cmp(last.end) > 0 {
+			last.end = i.end
+		}
+	}
+	return merged
+}
+
+// cidrs returns the fewest CIDRs covering exactly the interval
+func (i interval) cidrs(bits int) []string {
+	var cidrs []string
+
+	start := new(big.Int).Set(i.start)
+	for start.Cmp(i.end) <= 0 {
+		// grow the block while it stays aligned to start and within the interval
+		size := 0
+		for size < bits && start.Bit(size) == 0 {
+			blockEnd := new(big.Int).Lsh(one, uint(size+1))
+			blockEnd.Add(blockEnd, start).Sub(blockEnd, one)
+			if blockEnd.Cmp(i.end) > 0 {
+				break
+			}
+			size++
+		}
+
+		ip := make(net.IP, bits/8)
+		b := start.Bytes()
+		copy(ip[len(ip)-len(b):], b)
+		cidrs = append(cidrs, (&net.IPNet{IP: ip, Mask: net.CIDRMask(bits-size, bits)}).String())
+
+		start.Add(start, new(big.Int).Lsh(one, uint(size)))
+	}
+	return cidrs
+}
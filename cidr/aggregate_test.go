@@ -0,0 +1,62 @@
+package cidr_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jeffersongirao/source-ranges-controller/cidr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		expected []string
+	}{
+		{
+			name:     "adjacent",
+			values:   []string{"10.0.0.1/32", "10.0.0.0/32", "10.0.0.2/31"},
+			expected: []string{"10.0.0.0/30"},
+		},
+		{
+			name:     "overlapping",
+			values:   []string{"10.0.0.0/8", "10.1.0.0/16", "10.0.0.1"},
+			expected: []string{"10.0.0.0/8"},
+		},
+		{
+			name:     "adjacent but not aligned",
+			values:   []string{"10.0.0.1/32", "10.0.0.2/32"},
+			expected: []string{"10.0.0.1/32", "10.0.0.2/32"},
+		},
+		{
+			name:     "disjoint",
+			values:   []string{"192.168.0.0/24", "10.0.0.0/24"},
+			expected: []string{"10.0.0.0/24", "192.168.0.0/24"},
+		},
+		{
+			name:     "ipv6 and invalid",
+			values:   []string{"2001:db8::/33", "office", "2001:db8:8000::/33", "10.0.0.0/24"},
+			expected: []string{"10.0.0.0/24", "2001:db8::/32", "office"},
+		},
+		{
+			name:     "everything",
+			values:   []string{"0.0.0.0/1", "128.0.0.0/1"},
+			expected: []string{"0.0.0.0/0"},
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, cidr.Aggregate(test.values), test.name)
+	}
+}
+
+func TestAggregateAdjacentHosts(t *testing.T) {
+	values := make([]string, 0, 256)
+	for i := 0; i < 256; i++ {
+		values = append(values, fmt.Sprintf("203.0.113.%d", i))
+	}
+
+	assert.Equal(t, []string{"203.0.113.0/24"}, cidr.Aggregate(values))
+	assert.Equal(t, []string{"203.0.113.1/32", "203.0.113.2/31", "203.0.113.4/30", "203.0.113.8/29", "203.0.113.16/28", "203.0.113.32/27", "203.0.113.64/26", "203.0.113.128/25"}, cidr.Aggregate(values[1:]))
+}
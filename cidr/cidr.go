@@ -0,0 +1,39 @@
+package cidr
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Canonical parses a CIDR or a bare IP address and returns it as the network address
+// and prefix length, bare IPv4 addresses become /32 and bare IPv6 addresses become /128.
+func Canonical(value string) (string, error) {
+	ipNet, err := Parse(value)
+	if err != nil {
+		return "", err
+	}
+	return ipNet.String(), nil
+}
+
+// Parse parses a CIDR or a bare IP address into its network, host bits are masked
+func Parse(value string) (*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid CIDR address: %s", value)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, err
+	}
+	return ipNet, nil
+}
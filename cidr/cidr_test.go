@@ -0,0 +1,35 @@
+package cidr_test
+
+import (
+	"testing"
+
+	"github.com/jeffersongirao/source-ranges-controller/cidr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8"},
+		{" 10.0.0.0/8 ", "10.0.0.0/8"},
+		{"10.0.0.1", "10.0.0.1/32"},
+		{"10.1.2.3/8", "10.0.0.0/8"},
+		{"2001:db8::1", "2001:db8::1/128"},
+		{"2001:db8::1/32", "2001:db8::/32"},
+	}
+
+	for _, test := range tests {
+		value, err := cidr.Canonical(test.value)
+		assert.Nil(t, err, test.value)
+		assert.Equal(t, test.expected, value, test.value)
+	}
+}
+
+func TestCanonicalWithInvalidValues(t *testing.T) {
+	for _, value := range []string{"", "10.0.0.300/24", "10.0.0.0/33", "office", "10.0.0.0/"} {
+		_, err := cidr.Canonical(value)
+		assert.NotNil(t, err, value)
+	}
+}
@@ -0,0 +1,72 @@
+package cidr
+
+import (
+	"sort"
+	"strings"
+)
+
+// Set is a set of source ranges compared by their canonical form,
+// so order, duplicates and notation (10.0.0.1 vs 10.0.0.1/32) don't matter.
+type Set map[string]struct{}
+
+// NewSet returns a Set holding the given source ranges
+func NewSet(values ...string) Set {
+	s := make(Set, len(values))
+	s.Insert(values...)
+	return s
+}
+
+// Insert adds source ranges to the set, values that can't be parsed are kept as they are
+func (s Set) Insert(values ...string) {
+	for _, value := range values {
+		s[key(value)] = struct{}{}
+	}
+}
+
+// Has tells if the source range is in the set
+func (s Set) Has(value string) bool {
+	_, ok := s[key(value)]
+	return ok
+}
+
+// Equal tells if both sets hold the same source ranges
+func (s Set) Equal(other Set) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for value := range s {
+		if _, ok := other[value]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Difference returns the sorted source ranges in the set that are not in other
+func (s Set) Difference(other Set) []string {
+	var diff []string
+	for value := range s {
+		if _, ok := other[value]; !ok {
+			diff = append(diff, value)
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+// List returns the sorted source ranges of the set
+func (s Set) List() []string {
+	values := make([]string, 0, len(s))
+	for value := range s {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+func key(value string) string {
+	if canonical, err := Canonical(value); err == nil {
+		return canonical
+	}
+	return strings.TrimSpace(value)
+}
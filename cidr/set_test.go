@@ -0,0 +1,43 @@
+package cidr_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jeffersongirao/source-ranges-controller/cidr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetEqual(t *testing.T) {
+	assert.True(t, cidr.NewSet("10.0.0.1/32", "10.0.0.0/8").Equal(cidr.NewSet("10.0.0.0/8", "10.0.0.1", "10.0.0.0/8")))
+	assert.True(t, cidr.NewSet().Equal(cidr.NewSet()))
+	assert.False(t, cidr.NewSet("10.0.0.0/8").Equal(cidr.NewSet("10.0.0.0/16")))
+	assert.False(t, cidr.NewSet("10.0.0.0/8").Equal(cidr.NewSet("10.0.0.0/8", "10.0.0.1")))
+	assert.False(t, cidr.NewSet("10.0.0.0/8", "not-a-cidr").Equal(cidr.NewSet("10.0.0.0/8")))
+}
+
+func TestSetDifference(t *testing.T) {
+	s1 := cidr.NewSet("10.0.0.1", "10.0.0.2/32", "10.0.0.3/32")
+	s2 := cidr.NewSet("10.0.0.1/32", "10.0.0.4/32")
+
+	assert.Equal(t, []string{"10.0.0.2/32", "10.0.0.3/32"}, s1.Difference(s2))
+	assert.Equal(t, []string{"10.0.0.4/32"}, s2.Difference(s1))
+	assert.True(t, s1.Has("10.0.0.1/32"))
+	assert.Equal(t, []string{"10.0.0.1/32", "10.0.0.2/32", "10.0.0.3/32"}, s1.List())
+}
+
+func BenchmarkSetEqual(b *testing.B) {
+	values := make([]string, 0, 5000)
+	for i := 0; i < 5000; i++ {
+		values = append(values, fmt.Sprintf("10.%d.%d.0/24", i/256, i%256))
+	}
+	reversed := make([]string, len(values))
+	for i, value := range values {
+		reversed[len(values)-1-i] = value
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cidr.NewSet(values...).Equal(cidr.NewSet(reversed...))
+	}
+}
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"github.com/jeffersongirao/source-ranges-controller/apis/sourceranges/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	sourceRangeSetsResource        = "sourcerangesets"
+	clusterSourceRangeSetsResource = "clustersourcerangesets"
+)
+
+var (
+	scheme         = runtime.NewScheme()
+	codecs         = serializer.NewCodecFactory(scheme)
+	parameterCodec = runtime.NewParameterCodec(scheme)
+)
+
+func init() {
+	v1alpha1.AddToScheme(scheme)
+}
+
+// Interface is the client for the source ranges custom resources
+type Interface interface {
+	SourceRangeSets(namespace string) SourceRangeSetInterface
+	ClusterSourceRangeSets() ClusterSourceRangeSetInterface
+}
+
+// SourceRangeSetInterface has methods to work with SourceRangeSets
+type SourceRangeSetInterface interface {
+	Get(name string, options metav1.GetOptions) (*v1alpha1.SourceRangeSet, error)
+	List(options metav1.ListOptions) (*v1alpha1.SourceRangeSetList, error)
+	Watch(options metav1.ListOptions) (watch.Interface, error)
+	UpdateStatus(set *v1alpha1.SourceRangeSet) (*v1alpha1.SourceRangeSet, error)
+}
+
+// ClusterSourceRangeSetInterface has methods to work with ClusterSourceRangeSets
+type ClusterSourceRangeSetInterface interface {
+	Get(name string, options metav1.GetOptions) (*v1alpha1.ClusterSourceRangeSet, error)
+	List(options metav1.ListOptions) (*v1alpha1.ClusterSourceRangeSetList, error)
+	Watch(options metav1.ListOptions) (watch.Interface, error)
+	UpdateStatus(set *v1alpha1.ClusterSourceRangeSet) (*v1alpha1.ClusterSourceRangeSet, error)
+}
+
+// Client implements Interface on top of a REST client for the source ranges API group
+type Client struct {
+	restClient rest.Interface
+}
+
+// NewForConfig returns a new Client for the given configuration
+func NewForConfig(c *rest.Config) (*Client, error) {
+	config := *c
+	config.GroupVersion = &v1alpha1.SchemeGroupVersion
+	config.APIPath = "/apis"
+	config.ContentType = runtime.ContentTypeJSON
+	config.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: codecs}
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+
+	restClient, err := rest.RESTClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{restClient: restClient}, nil
+}
+
+func (c *Client) SourceRangeSets(namespace string) SourceRangeSetInterface {
+	return &sourceRangeSets{client: c.restClient, ns: namespace}
+}
+
+func (c *Client) ClusterSourceRangeSets() ClusterSourceRangeSetInterface {
+	return &clusterSourceRangeSets{client: c.restClient}
+}
+
+type sourceRangeSets struct {
+	client rest.Interface
+	ns     string
+}
+
+func (c *sourceRangeSets) Get(name string, options metav1.GetOptions) (*v1alpha1.SourceRangeSet, error) {
+	result := &v1alpha1.SourceRangeSet{}
+	err := c.client.Get().
+		Namespace(c.ns).
+		Resource(sourceRangeSetsResource).
+		Name(name).
+		VersionedParams(&options, parameterCodec).
+		Do().
+		Into(result)
+	return result, err
+}
+
+func (c *sourceRangeSets) List(options metav1.ListOptions) (*v1alpha1.SourceRangeSetList, error) {
+	result := &v1alpha1.SourceRangeSetList{}
+	err := c.client.Get().
+		Namespace(c.ns).
+		Resource(sourceRangeSetsResource).
+		VersionedParams(&options, parameterCodec).
+		Do().
+		Into(result)
+	return result, err
+}
+
+func (c *sourceRangeSets) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	options.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource(sourceRangeSetsResource).
+		VersionedParams(&options, parameterCodec).
+		Watch()
+}
+
+func (c *sourceRangeSets) UpdateStatus(set *v1alpha1.SourceRangeSet) (*v1alpha1.SourceRangeSet, error) {
+	result := &v1alpha1.SourceRangeSet{}
+	err := c.client.Put().
+		Namespace(c.ns).
+		Resource(sourceRangeSetsResource).
+		Name(set.Name).
+		SubResource("status").
+		Body(set).
+		Do().
+		Into(result)
+	return result, err
+}
+
+type clusterSourceRangeSets struct {
+	client rest.Interface
+}
+
+func (c *clusterSourceRangeSets) Get(name string, options metav1.GetOptions) (*v1alpha1.ClusterSourceRangeSet, error) {
+	result := &v1alpha1.ClusterSourceRangeSet{}
+	err := c.client.Get().
+		Resource(clusterSourceRangeSetsResource).
+		Name(name).
+		VersionedParams(&options, parameterCodec).
+		Do().
+		Into(result)
+	return result, err
+}
+
+func (c *clusterSourceRangeSets) List(options metav1.ListOptions) (*v1alpha1.ClusterSourceRangeSetList, error) {
+	result := &v1alpha1.ClusterSourceRangeSetList{}
+	err := c.client.Get().
+		Resource(clusterSourceRangeSetsResource).
+		VersionedParams(&options, parameterCodec).
+		Do().
+		Into(result)
+	return result, err
+}
+
+func (c *clusterSourceRangeSets) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	options.Watch = true
+	return c.client.Get().
+		Resource(clusterSourceRangeSetsResource).
+		VersionedParams(&options, parameterCodec).
+		Watch()
+}
+
+func (c *clusterSourceRangeSets) UpdateStatus(set *v1alpha1.ClusterSourceRangeSet) (*v1alpha1.ClusterSourceRangeSet, error) {
+	result := &v1alpha1.ClusterSourceRangeSet{}
+	err := c.client.Put().
+		Resource(clusterSourceRangeSetsResource).
+		Name(set.Name).
+		SubResource("status").
+		Body(set).
+		Do().
+		Into(result)
+	return result, err
+}
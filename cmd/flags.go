@@ -2,26 +2,97 @@ package main
 
 import (
 	"flag"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/jeffersongirao/source-ranges-controller/controller"
+	"github.com/jeffersongirao/source-ranges-controller/leaderelection"
+	"github.com/jeffersongirao/source-ranges-controller/server"
 	"k8s.io/client-go/util/homedir"
 )
 
 type Flags struct {
 	flagSet *flag.FlagSet
 
-	Development bool
-	ResyncSec   int
-	KubeConfig  string
-	Namespace   string
+	Development             bool
+	ResyncSec               int
+	Workers                 int
+	MaxRetries              int
+	RetryBaseBackoff        time.Duration
+	RetryMaxBackoff         time.Duration
+	ProgressTimeout         time.Duration
+	ShutdownTimeout         time.Duration
+	KubeConfig              string
+	Namespace               string
+	Namespaces              string
+	ExcludeNamespaces       string
+	NamespaceSelector       string
+	SourceRangeSets         bool
+	AggregateSourceRanges   bool
+	MaxSourceRanges         int
+	EmptySourceRangesPolicy string
+	DryRun                  bool
+
+	MetricsAddress         string
+	MetricsTLSCertFile     string
+	MetricsTLSKeyFile      string
+	MetricsTokenReviewAuth bool
+
+	LeaderElect              bool
+	LeaderElectNamespace     string
+	LeaderElectName          string
+	LeaderElectIdentity      string
+	LeaderElectLeaseDuration time.Duration
+	LeaderElectRenewDeadline time.Duration
+	LeaderElectRetryPeriod   time.Duration
 }
 
 func (f *Flags) ControllerConfig() controller.Config {
 	return controller.Config{
-		ResyncPeriod: time.Duration(f.ResyncSec) * time.Second,
+		ResyncPeriod:     time.Duration(f.ResyncSec) * time.Second,
+		Namespace:        f.Namespace,
+		Workers:          f.Workers,
+		MaxRetries:       f.MaxRetries,
+		RetryBaseBackoff: f.RetryBaseBackoff,
+		RetryMaxBackoff:  f.RetryMaxBackoff,
+		ProgressTimeout:  f.ProgressTimeout,
+		SourceRangeSets:  f.SourceRangeSets,
+
+		Namespaces:        splitList(f.Namespaces),
+		ExcludeNamespaces: splitList(f.ExcludeNamespaces),
+		NamespaceSelector: f.NamespaceSelector,
+
+		AggregateSourceRanges:   f.AggregateSourceRanges,
+		MaxSourceRanges:         f.MaxSourceRanges,
+		EmptySourceRangesPolicy: f.EmptySourceRangesPolicy,
+		DryRun:                  f.DryRun,
+	}
+}
+
+func (f *Flags) LeaderElectionConfig() leaderelection.Config {
+	namespace := f.LeaderElectNamespace
+	if namespace == "" {
+		namespace = podNamespace()
+	}
+
+	return leaderelection.Config{
+		Namespace:     namespace,
+		Name:          f.LeaderElectName,
+		Identity:      f.LeaderElectIdentity,
+		LeaseDuration: f.LeaderElectLeaseDuration,
+		RenewDeadline: f.LeaderElectRenewDeadline,
+		RetryPeriod:   f.LeaderElectRetryPeriod,
+	}
+}
+
+func (f *Flags) ServerConfig() server.Config {
+	return server.Config{
+		Addr:        f.MetricsAddress,
+		TLSCertFile: f.MetricsTLSCertFile,
+		TLSKeyFile:  f.MetricsTLSKeyFile,
 	}
 }
 
@@ -31,13 +102,60 @@ func NewFlags() *Flags {
 	}
 
 	kubehome := filepath.Join(homedir.HomeDir(), ".kube", "config")
+	hostname, _ := os.Hostname()
 
 	f.flagSet.IntVar(&f.ResyncSec, "resync-seconds", 30, "The number of seconds the controller will resync the resources")
+	f.flagSet.IntVar(&f.Workers, "workers", 1, "The number of workers processing the changes of each kind of resource concurrently")
+	f.flagSet.IntVar(&f.MaxRetries, "max-retries", 5, "The number of times a failed change is retried before waiting for the next resync")
+	f.flagSet.DurationVar(&f.RetryBaseBackoff, "retry-base-backoff", time.Second, "How long to wait before the first retry of a failed change, doubled on every retry")
+	f.flagSet.DurationVar(&f.RetryMaxBackoff, "retry-max-backoff", 2*time.Minute, "The longest wait between retries of a failed change")
+	f.flagSet.DurationVar(&f.ProgressTimeout, "progress-timeout", 5*time.Minute, "Fails the liveness check when a change has been processed for longer than this, 0 disables the check")
+	f.flagSet.DurationVar(&f.ShutdownTimeout, "shutdown-timeout", 20*time.Second, "How long to wait for the changes being processed and the pending events on shutdown")
 	f.flagSet.StringVar(&f.KubeConfig, "kubeconfig", kubehome, "kubernetes configuration path, only used when development mode enabled")
 	f.flagSet.StringVar(&f.Namespace, "namespace", "", "kubernetes namespace to watch for resources, if unset it will watch all namepaces")
+	f.flagSet.StringVar(&f.Namespaces, "namespaces", "", "comma-separated kubernetes namespaces to watch for resources, added to --namespace")
+	f.flagSet.StringVar(&f.ExcludeNamespaces, "exclude-namespaces", "", "comma-separated kubernetes namespaces whose Services are never managed, e.g. kube-system")
+	f.flagSet.StringVar(&f.NamespaceSelector, "namespace-selector", "", "label selector on Namespace objects, only Services from matching namespaces are managed")
+	f.flagSet.BoolVar(&f.SourceRangeSets, "source-range-sets", false, "enables SourceRangeSet and ClusterSourceRangeSet custom resources as source of ranges, the CRDs must be installed")
+	f.flagSet.BoolVar(&f.AggregateSourceRanges, "aggregate-source-ranges", false, "merges adjacent and overlapping source ranges into the smallest equivalent list before applying them")
+	f.flagSet.IntVar(&f.MaxSourceRanges, "max-source-ranges", 0, "emits a warning event when a Service gets more source ranges than this, 0 means no limit")
+	f.flagSet.StringVar(&f.EmptySourceRangesPolicy, "empty-source-ranges-policy", "keep", "what to do when the referenced sources hold no source ranges: keep the last applied ones, deny everyone or allow everyone")
+	f.flagSet.BoolVar(&f.DryRun, "dry-run", false, "reports the changes to the Services in events and metrics instead of applying them")
+	f.flagSet.StringVar(&f.MetricsAddress, "metrics-address", ":7777", "address serving the metrics and the health checks")
+	f.flagSet.StringVar(&f.MetricsTLSCertFile, "metrics-tls-cert-file", "", "TLS certificate file of the metrics server, reloaded when it changes")
+	f.flagSet.StringVar(&f.MetricsTLSKeyFile, "metrics-tls-key-file", "", "TLS key file of the metrics server, reloaded when it changes")
+	f.flagSet.BoolVar(&f.MetricsTokenReviewAuth, "metrics-token-review-auth", false, "requires a bearer token authenticated through the Kubernetes TokenReview API to read the metrics")
+	f.flagSet.BoolVar(&f.LeaderElect, "leader-elect", false, "elects a leader among the replicas, only the leader manages Services")
+	f.flagSet.StringVar(&f.LeaderElectNamespace, "leader-elect-namespace", "", "namespace of the leader election lock ConfigMap, defaults to the controller namespace")
+	f.flagSet.StringVar(&f.LeaderElectName, "leader-elect-name", "source-ranges-controller", "name of the leader election lock ConfigMap")
+	f.flagSet.StringVar(&f.LeaderElectIdentity, "leader-elect-identity", hostname, "identity of this replica in the leader election, defaults to the hostname")
+	f.flagSet.DurationVar(&f.LeaderElectLeaseDuration, "leader-elect-lease-duration", 15*time.Second, "how long standbys wait before taking over a leadership that isn't renewed")
+	f.flagSet.DurationVar(&f.LeaderElectRenewDeadline, "leader-elect-renew-deadline", 10*time.Second, "how long the leader keeps trying to renew its leadership before giving up")
+	f.flagSet.DurationVar(&f.LeaderElectRetryPeriod, "leader-elect-retry-period", 2*time.Second, "how long to wait between leader election tries")
 	f.flagSet.BoolVar(&f.Development, "development", false, "development flag will allow to run the operator outside a kubernetes cluster")
 
 	f.flagSet.Parse(os.Args[1:])
 
 	return f
 }
+
+// splitList returns the non-empty values of a comma-separated flag
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// podNamespace returns the namespace the controller runs in, default when running outside a cluster
+func podNamespace() string {
+	if data, err := ioutil.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
+		if namespace := strings.TrimSpace(string(data)); namespace != "" {
+			return namespace
+		}
+	}
+	return "default"
+}
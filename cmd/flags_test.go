@@ -0,0 +1,20 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControllerConfigNamespaces(t *testing.T) {
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{"source-ranges-controller", "--namespace=team-a", "--namespaces=team-b, team-c", "--exclude-namespaces=kube-system"}
+
+	config := NewFlags().ControllerConfig()
+
+	assert.Equal(t, "team-a", config.Namespace)
+	assert.Equal(t, []string{"team-b", "team-c"}, config.Namespaces)
+	assert.Equal(t, []string{"kube-system"}, config.ExcludeNamespaces)
+}
@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/jeffersongirao/source-ranges-controller/client"
 	"github.com/jeffersongirao/source-ranges-controller/controller"
+	"github.com/jeffersongirao/source-ranges-controller/health"
+	"github.com/jeffersongirao/source-ranges-controller/leaderelection"
 	"github.com/jeffersongirao/source-ranges-controller/log"
+	"github.com/jeffersongirao/source-ranges-controller/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	applogger "github.com/spotahome/kooper/log"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
@@ -31,7 +40,7 @@ func New(logger log.Logger) *Main {
 	}
 }
 
-func (m *Main) getKubernetesClient() (kubernetes.Interface, error) {
+func (m *Main) getKubernetesConfig() (*rest.Config, error) {
 	var err error
 	var cfg *rest.Config
 
@@ -48,23 +57,131 @@ func (m *Main) getKubernetesClient() (kubernetes.Interface, error) {
 		}
 	}
 
-	return kubernetes.NewForConfig(cfg)
+	return cfg, nil
 }
 
 func (m *Main) Run(stopC <-chan struct{}) error {
 	m.logger.Infof("initializing source ranges controller")
 
-	k8sCli, err := m.getKubernetesClient()
+	cfg, err := m.getKubernetesConfig()
 	if err != nil {
 		return err
 	}
 
-	ctrl, err := controller.New(m.config, k8sCli, m.logger)
+	k8sCli, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
 		return err
 	}
 
-	return ctrl.Run(stopC)
+	srsCli, err := client.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	reg := prometheus.NewRegistry()
+	ctrl, err := controller.New(m.config, k8sCli, srsCli, reg, m.logger)
+	if err != nil {
+		return err
+	}
+
+	checks := health.NewChecks()
+	checks.AddLivenessCheck("progress", ctrl.Progressing)
+
+	if !m.flags.LeaderElect {
+		checks.AddReadinessCheck("caches", ctrl.Synced)
+		srv, err := m.serveHTTP(k8sCli, reg, checks)
+		if err != nil {
+			return err
+		}
+		err = m.runController(ctrl, stopC, m.flags.ShutdownTimeout)
+		m.shutdown(srv)
+		return err
+	}
+
+	leCfg := m.flags.LeaderElectionConfig()
+	if leCfg.Identity == "" {
+		return fmt.Errorf("leader election identity is required")
+	}
+	if err := leCfg.Validate(); err != nil {
+		return fmt.Errorf("invalid leader election configuration: %v", err)
+	}
+	elector := leaderelection.New(leCfg, k8sCli, ctrl.MetricsRecorder(), m.logger)
+
+	// Standbys don't watch any resource, so they are ready as soon as they know they aren't the leader.
+	checks.AddReadinessCheck("leader-election", func() error {
+		if !elector.RoleKnown() {
+			return fmt.Errorf("leader election role not known yet")
+		}
+		return nil
+	})
+	checks.AddReadinessCheck("caches", func() error {
+		if !elector.IsLeader() {
+			return nil
+		}
+		return ctrl.Synced()
+	})
+	srv, err := m.serveHTTP(k8sCli, reg, checks)
+	if err != nil {
+		return err
+	}
+	// The controller is drained before the leadership is released, within the time left before a
+	// standby can take over, so it never does while the changes being processed are still being applied.
+	drainTimeout := m.flags.ShutdownTimeout
+	if leCfg.LeadStopTimeout() < drainTimeout {
+		drainTimeout = leCfg.LeadStopTimeout()
+	}
+	err = elector.Run(stopC, func(leadStopC <-chan struct{}) error {
+		return m.runController(ctrl, leadStopC, drainTimeout)
+	})
+	m.shutdown(srv)
+	return err
+}
+
+// runController runs the controller until stopC is closed, then waits for the changes being
+// processed to finish and sends the pending events, failing when they don't within the drain timeout.
+func (m *Main) runController(ctrl *controller.Controller, stopC <-chan struct{}, drainTimeout time.Duration) error {
+	err := ctrl.Run(stopC)
+
+	m.logger.Infof("shutting down, waiting up to %s for the changes being processed", drainTimeout)
+	if drainErr := ctrl.Drain(drainTimeout); drainErr != nil {
+		if err != nil {
+			m.logger.Warningf("could not drain the controller: %v", drainErr)
+			return err
+		}
+		return fmt.Errorf("could not drain the controller: %v", drainErr)
+	}
+	return err
+}
+
+// shutdown stops serving HTTP, giving up after the shutdown timeout.
+func (m *Main) shutdown(srv *server.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.flags.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		m.logger.Warningf("could not shut down the metrics and health checks server: %v", err)
+	}
+}
+
+// serveHTTP serves the metrics and the health checks in the background, the health
+// checks are never authenticated so the kubelet can probe them.
+func (m *Main) serveHTTP(k8sCli kubernetes.Interface, reg *prometheus.Registry, checks *health.Checks) (*server.Server, error) {
+	var metricsHandler http.Handler = promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+	if m.flags.MetricsTokenReviewAuth {
+		metricsHandler = server.NewTokenReviewAuth(k8sCli, m.logger).Wrap(metricsHandler)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler)
+	mux.Handle("/healthz", checks.LivenessHandler())
+	mux.Handle("/readyz", checks.ReadinessHandler())
+
+	srv, err := server.New(m.flags.ServerConfig(), mux, m.logger)
+	if err != nil {
+		return nil, err
+	}
+	srv.Run()
+	return srv, nil
 }
 
 func main() {
@@ -89,5 +206,18 @@ func main() {
 		}
 	case <-signalC:
 		logger.Infof("Signal captured, exiting...")
+		close(stopC)
+
+		// Run returns once the controller is drained, a second signal exits right away.
+		select {
+		case err := <-finishC:
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error running controller: %s", err)
+				os.Exit(1)
+			}
+		case <-signalC:
+			logger.Warningf("Signal captured again, exiting without draining...")
+			os.Exit(1)
+		}
 	}
 }
@@ -5,4 +5,26 @@ import "time"
 type Config struct {
 	ResyncPeriod time.Duration
 	Namespace    string
+	Workers      int
+
+	// MaxRetries is how many times a failed change is retried before waiting for the next resync,
+	// with an exponential backoff from RetryBaseBackoff up to RetryMaxBackoff between retries.
+	MaxRetries       int
+	RetryBaseBackoff time.Duration
+	RetryMaxBackoff  time.Duration
+
+	// ProgressTimeout is how long a change can be processed before the controller
+	// is no longer considered alive, 0 disables the check.
+	ProgressTimeout time.Duration
+
+	Namespaces        []string
+	ExcludeNamespaces []string
+	NamespaceSelector string
+
+	SourceRangeSets bool
+
+	AggregateSourceRanges   bool
+	MaxSourceRanges         int
+	EmptySourceRangesPolicy string
+	DryRun                  bool
 }
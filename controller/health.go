@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressTracker keeps track of the jobs being processed by the handlers,
+// so a worker that stopped making progress can be detected.
+type progressTracker struct {
+	mu       sync.Mutex
+	next     uint64
+	inFlight map[uint64]time.Time
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{
+		inFlight: map[uint64]time.Time{},
+	}
+}
+
+// track records a job being processed until the returned func is called.
+func (p *progressTracker) track() func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.next++
+	id := p.next
+	p.inFlight[id] = time.Now()
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		delete(p.inFlight, id)
+	}
+}
+
+// oldest returns when the job being processed for the longest time started, zero when there's none.
+func (p *progressTracker) oldest() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var oldest time.Time
+	for _, started := range p.inFlight {
+		if oldest.IsZero() || started.Before(oldest) {
+			oldest = started
+		}
+	}
+	return oldest
+}
+
+// Synced tells if the informer caches of every watched resource have synced.
+func (c *Controller) Synced() error {
+	var pending []string
+	for _, ctrl := range c.controllers {
+		if !ctrl.informer.HasSynced() {
+			pending = append(pending, ctrl.kind)
+		}
+	}
+	if len(pending) != 0 {
+		return fmt.Errorf("waiting for caches to sync: %s", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// Progressing tells if the workers are making progress, failing when a job has been
+// processed for longer than the progress timeout.
+func (c *Controller) Progressing() error {
+	if c.config.ProgressTimeout <= 0 {
+		return nil
+	}
+
+	started := c.progress.oldest()
+	if !started.IsZero() && time.Since(started) > c.config.ProgressTimeout {
+		return fmt.Errorf("a job has been processing for %s, more than %s", time.Since(started).Round(time.Second), c.config.ProgressTimeout)
+	}
+	return nil
+}
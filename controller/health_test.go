@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControllerSyncedOnceTheInformerCachesSynced(t *testing.T) {
+	ctrl := newTestRetryController(&failingHandler{}, &fakeRetryMetrics{})
+	c := &Controller{controllers: []*retryController{ctrl}}
+
+	assert.EqualError(t, c.Synced(), "waiting for caches to sync: Service")
+
+	stopC := make(chan struct{})
+	defer close(stopC)
+	go ctrl.informer.Run(stopC)
+
+	deadline := time.Now().Add(time.Second)
+	for c.Synced() != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NoError(t, c.Synced())
+}
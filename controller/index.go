@@ -0,0 +1,258 @@
+package controller
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/jeffersongirao/source-ranges-controller/apis/sourceranges/v1alpha1"
+	"github.com/jeffersongirao/source-ranges-controller/client"
+	"github.com/jeffersongirao/source-ranges-controller/service"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	configMapKind             = "ConfigMap"
+	sourceRangeSetKind        = "SourceRangeSet"
+	clusterSourceRangeSetKind = "ClusterSourceRangeSet"
+	secretKind                = "Secret"
+)
+
+// serviceIndex keeps track of the Services managed by the controller and of the
+// sources (ConfigMaps, SourceRangeSets and ClusterSourceRangeSets) they reference,
+// so a source change can be mapped back to the Services that need to be enforced
+// again. It also caches the referenced sources so enforcing a Service doesn't
+// need to hit the API server. Secrets are tracked as references too but never
+// watched nor cached, they are read on every enforcement.
+type serviceIndex struct {
+	client    kubernetes.Interface
+	srsClient client.Interface
+
+	mu         sync.RWMutex
+	services   map[string]*corev1.Service
+	references map[string][]string
+	referrers  map[string]map[string]struct{}
+	sources    map[string]runtime.Object
+}
+
+func newServiceIndex(client kubernetes.Interface, srsClient client.Interface) *serviceIndex {
+	return &serviceIndex{
+		client:     client,
+		srsClient:  srsClient,
+		services:   map[string]*corev1.Service{},
+		references: map[string][]string{},
+		referrers:  map[string]map[string]struct{}{},
+		sources:    map[string]runtime.Object{},
+	}
+}
+
+// setService stores the Service and refreshes the sources it references.
+func (s *serviceIndex) setService(svc *corev1.Service) {
+	key, err := cache.MetaNamespaceKeyFunc(svc)
+	if err != nil {
+		return
+	}
+
+	refs := serviceReferences(svc)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Only the sources the Service stopped referencing are dropped, so the cached
+	// ones survive the Service updates and resyncs.
+	s.unreference(key, refs...)
+	if len(refs) == 0 {
+		delete(s.services, key)
+		return
+	}
+
+	s.services[key] = svc
+	s.references[key] = refs
+	for _, ref := range refs {
+		if s.referrers[ref] == nil {
+			s.referrers[ref] = map[string]struct{}{}
+		}
+		s.referrers[ref][key] = struct{}{}
+	}
+}
+
+// deleteService forgets the Service with the given key.
+func (s *serviceIndex) deleteService(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.unreference(key)
+	delete(s.services, key)
+}
+
+// setSource stores the source if any Service references it and returns those
+// Services when the source content changed since it was last seen.
+func (s *serviceIndex) setSource(kind string, obj runtime.Object) []*corev1.Service {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return nil
+	}
+	ref := reference(kind, key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.referrers[ref]) == 0 {
+		delete(s.sources, ref)
+		return nil
+	}
+
+	old, ok := s.sources[ref]
+	s.sources[ref] = obj
+	if ok && sameSourceRanges(old, obj) {
+		return nil
+	}
+	return s.referringServices(ref)
+}
+
+// deleteSource forgets the source with the given key and returns the Services referencing it.
+func (s *serviceIndex) deleteSource(kind, key string) []*corev1.Service {
+	ref := reference(kind, key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sources, ref)
+	return s.referringServices(ref)
+}
+
+// consumers returns the number of Services referencing the source with the given key.
+func (s *serviceIndex) consumers(kind, key string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.referrers[reference(kind, key)])
+}
+
+// GetConfigMap returns the cached ConfigMap, falling back to the API server
+// when the ConfigMap controller hasn't seen it yet.
+func (s *serviceIndex) GetConfigMap(namespace, name string) (*corev1.ConfigMap, error) {
+	if cm, ok := s.source(configMapKind, namespace+"/"+name).(*corev1.ConfigMap); ok {
+		return cm, nil
+	}
+	return s.client.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+}
+
+// GetSourceRangeSet returns the cached SourceRangeSet, falling back to the API server
+// when the SourceRangeSet controller hasn't seen it yet.
+func (s *serviceIndex) GetSourceRangeSet(namespace, name string) (*v1alpha1.SourceRangeSet, error) {
+	if set, ok := s.source(sourceRangeSetKind, namespace+"/"+name).(*v1alpha1.SourceRangeSet); ok {
+		return set, nil
+	}
+	return s.srsClient.SourceRangeSets(namespace).Get(name, metav1.GetOptions{})
+}
+
+// GetClusterSourceRangeSet returns the cached ClusterSourceRangeSet, falling back to the API server
+// when the ClusterSourceRangeSet controller hasn't seen it yet.
+func (s *serviceIndex) GetClusterSourceRangeSet(name string) (*v1alpha1.ClusterSourceRangeSet, error) {
+	if set, ok := s.source(clusterSourceRangeSetKind, name).(*v1alpha1.ClusterSourceRangeSet); ok {
+		return set, nil
+	}
+	return s.srsClient.ClusterSourceRangeSets().Get(name, metav1.GetOptions{})
+}
+
+// namespaceServices returns the Services from the namespace referencing any source.
+func (s *serviceIndex) namespaceServices(namespace string) []*corev1.Service {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var services []*corev1.Service
+	for _, svc := range s.services {
+		if svc.ObjectMeta.Namespace == namespace {
+			services = append(services, svc)
+		}
+	}
+	return services
+}
+
+func (s *serviceIndex) source(kind, key string) runtime.Object {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sources[reference(kind, key)]
+}
+
+// unreference removes the references of the Service with the given key but the kept ones,
+// forgetting the sources no Service references anymore.
+func (s *serviceIndex) unreference(key string, kept ...string) {
+	keep := make(map[string]struct{}, len(kept))
+	for _, ref := range kept {
+		keep[ref] = struct{}{}
+	}
+
+	for _, ref := range s.references[key] {
+		if _, ok := keep[ref]; ok {
+			continue
+		}
+		delete(s.referrers[ref], key)
+		if len(s.referrers[ref]) == 0 {
+			delete(s.referrers, ref)
+			delete(s.sources, ref)
+		}
+	}
+	delete(s.references, key)
+}
+
+func (s *serviceIndex) referringServices(ref string) []*corev1.Service {
+	services := make([]*corev1.Service, 0, len(s.referrers[ref]))
+	for key := range s.referrers[ref] {
+		services = append(services, s.services[key])
+	}
+	return services
+}
+
+func reference(kind, key string) string {
+	return kind + "/" + key
+}
+
+func serviceReferences(svc *corev1.Service) []string {
+	var refs []string
+	for _, key := range service.ConfigMapReferences(svc) {
+		refs = append(refs, reference(configMapKind, key))
+	}
+
+	for _, key := range service.SecretReferences(svc) {
+		refs = append(refs, reference(secretKind, key))
+	}
+
+	sets, clusterSets := service.SourceRangeSetReferences(svc)
+	for _, key := range sets {
+		refs = append(refs, reference(sourceRangeSetKind, key))
+	}
+	for _, key := range clusterSets {
+		refs = append(refs, reference(clusterSourceRangeSetKind, key))
+	}
+	return refs
+}
+
+// sameSourceRanges tells if two versions of a source hold the same source ranges,
+// SourceRangeSets are compared by spec so status updates don't trigger enforcements.
+func sameSourceRanges(old, new runtime.Object) bool {
+	switch new := new.(type) {
+	case *v1alpha1.SourceRangeSet:
+		old, ok := old.(*v1alpha1.SourceRangeSet)
+		return ok && reflect.DeepEqual(old.Spec, new.Spec)
+	case *v1alpha1.ClusterSourceRangeSet:
+		old, ok := old.(*v1alpha1.ClusterSourceRangeSet)
+		return ok && reflect.DeepEqual(old.Spec, new.Spec)
+	}
+
+	oldMeta, err := meta.Accessor(old)
+	if err != nil {
+		return false
+	}
+	newMeta, err := meta.Accessor(new)
+	if err != nil {
+		return false
+	}
+	return oldMeta.GetResourceVersion() == newMeta.GetResourceVersion()
+}
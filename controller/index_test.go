@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/jeffersongirao/source-ranges-controller/apis/sourceranges/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newIndexedService(name string, annotations map[string]string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   metav1.NamespaceDefault,
+			Name:        name,
+			Annotations: annotations,
+		},
+	}
+}
+
+func newIndexedConfigMap(resourceVersion string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       metav1.NamespaceDefault,
+			Name:            "test-config",
+			ResourceVersion: resourceVersion,
+		},
+		Data: map[string]string{
+			"test": "10.0.0.0/8",
+		},
+	}
+}
+
+func TestServiceIndexMapsSourcesToServices(t *testing.T) {
+	index := newServiceIndex(fake.NewSimpleClientset(), nil)
+
+	svc := newIndexedService("test-service", map[string]string{
+		"source-ranges.alpha.girao.net/config-map": "test-config",
+	})
+	index.setService(svc)
+	index.setService(newIndexedService("unmanaged", nil))
+
+	assert.Equal(t, 1, index.consumers(configMapKind, "default/test-config"))
+	assert.Equal(t, []*corev1.Service{svc}, index.setSource(configMapKind, newIndexedConfigMap("1")))
+	assert.Empty(t, index.setSource(configMapKind, newIndexedConfigMap("1")), "Expected no Service when the ConfigMap didn't change")
+	assert.Equal(t, []*corev1.Service{svc}, index.setSource(configMapKind, newIndexedConfigMap("2")))
+	assert.Equal(t, []*corev1.Service{svc}, index.namespaceServices(metav1.NamespaceDefault))
+
+	assert.Equal(t, []*corev1.Service{svc}, index.deleteSource(configMapKind, "default/test-config"))
+}
+
+func TestServiceIndexCachesReferencedSources(t *testing.T) {
+	k8sCli := fake.NewSimpleClientset(newIndexedConfigMap("1"))
+	index := newServiceIndex(k8sCli, nil)
+
+	assert.Nil(t, index.setSource(configMapKind, newIndexedConfigMap("2")), "Expected no Service referencing the ConfigMap")
+	cm, err := index.GetConfigMap(metav1.NamespaceDefault, "test-config")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", cm.ObjectMeta.ResourceVersion, "Expected unreferenced ConfigMaps to be read from the API server")
+
+	index.setService(newIndexedService("test-service", map[string]string{
+		"source-ranges.alpha.girao.net/config-map": "test-config",
+	}))
+	index.setSource(configMapKind, newIndexedConfigMap("2"))
+	cm, err = index.GetConfigMap(metav1.NamespaceDefault, "test-config")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", cm.ObjectMeta.ResourceVersion, "Expected referenced ConfigMaps to be read from the cache")
+
+	index.deleteService("default/test-service")
+	assert.Equal(t, 0, index.consumers(configMapKind, "default/test-config"))
+	assert.Nil(t, index.source(configMapKind, "default/test-config"))
+}
+
+func TestServiceIndexForgetsServicesWithoutReferences(t *testing.T) {
+	index := newServiceIndex(fake.NewSimpleClientset(), nil)
+
+	index.setService(newIndexedService("test-service", map[string]string{
+		"source-ranges.alpha.girao.net/config-map": "test-config",
+		"source-ranges.alpha.girao.net/secret":     "partners",
+	}))
+	assert.Equal(t, 1, index.consumers(secretKind, "default/partners"))
+
+	index.setService(newIndexedService("test-service", nil))
+	assert.Equal(t, 0, index.consumers(configMapKind, "default/test-config"))
+	assert.Equal(t, 0, index.consumers(secretKind, "default/partners"))
+	assert.Empty(t, index.namespaceServices(metav1.NamespaceDefault))
+}
+
+func TestServiceIndexIgnoresSourceRangeSetStatusChanges(t *testing.T) {
+	index := newServiceIndex(fake.NewSimpleClientset(), nil)
+
+	svc := newIndexedService("test-service", map[string]string{
+		"source-ranges.alpha.girao.net/source-range-sets": "vendors",
+	})
+	index.setService(svc)
+
+	set := &v1alpha1.SourceRangeSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       metav1.NamespaceDefault,
+			Name:            "vendors",
+			ResourceVersion: "1",
+		},
+		Spec: v1alpha1.SourceRangeSetSpec{
+			SourceRanges: []v1alpha1.SourceRange{{CIDR: "10.0.0.0/8"}},
+		},
+	}
+	assert.Equal(t, []*corev1.Service{svc}, index.setSource(sourceRangeSetKind, set))
+
+	updated := set.DeepCopy()
+	updated.ObjectMeta.ResourceVersion = "2"
+	updated.Status.Consumers = 1
+	assert.Empty(t, index.setSource(sourceRangeSetKind, updated))
+
+	updated = updated.DeepCopy()
+	updated.Spec.SourceRanges = append(updated.Spec.SourceRanges, v1alpha1.SourceRange{CIDR: "192.168.0.0/16"})
+	assert.Equal(t, []*corev1.Service{svc}, index.setSource(sourceRangeSetKind, updated))
+}
+
+func TestServiceIndexKeepsSourcesCachedAcrossServiceUpdates(t *testing.T) {
+	index := newServiceIndex(fake.NewSimpleClientset(), nil)
+
+	svc := newIndexedService("test-service", map[string]string{
+		"source-ranges.alpha.girao.net/config-map": "test-config",
+	})
+	index.setService(svc)
+	index.setSource(configMapKind, newIndexedConfigMap("1"))
+
+	index.setService(svc)
+	assert.NotNil(t, index.source(configMapKind, "default/test-config"), "Expected the ConfigMap to stay cached")
+	assert.Empty(t, index.setSource(configMapKind, newIndexedConfigMap("1")), "Expected no Service when the ConfigMap didn't change")
+
+	index.setService(newIndexedService("test-service", map[string]string{
+		"source-ranges.alpha.girao.net/config-map": "test-config,other-config",
+	}))
+	assert.NotNil(t, index.source(configMapKind, "default/test-config"))
+	assert.Equal(t, 1, index.consumers(configMapKind, "default/other-config"))
+
+	index.setService(newIndexedService("test-service", map[string]string{
+		"source-ranges.alpha.girao.net/config-map": "other-config",
+	}))
+	assert.Nil(t, index.source(configMapKind, "default/test-config"), "Expected the ConfigMap no longer referenced to be dropped")
+	assert.Equal(t, 0, index.consumers(configMapKind, "default/test-config"))
+	assert.Equal(t, 1, index.consumers(configMapKind, "default/other-config"))
+}
@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jeffersongirao/source-ranges-controller/service"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// namespaceFilter tells if the Services of a namespace are managed by the controller,
+// based on the watched and excluded namespaces and on the namespace label selector.
+type namespaceFilter struct {
+	namespaces map[string]bool
+	excluded   map[string]bool
+	selector   labels.Selector
+
+	mu       sync.RWMutex
+	selected map[string]bool
+}
+
+func newNamespaceFilter(namespaces, excluded []string, selector labels.Selector) *namespaceFilter {
+	f := &namespaceFilter{
+		namespaces: map[string]bool{},
+		excluded:   map[string]bool{},
+		selector:   selector,
+		selected:   map[string]bool{},
+	}
+	for _, namespace := range namespaces {
+		if namespace != metav1.NamespaceAll {
+			f.namespaces[namespace] = true
+		}
+	}
+	for _, namespace := range excluded {
+		f.excluded[namespace] = true
+	}
+	return f
+}
+
+// allowed tells if the Services of the namespace are managed by the controller.
+func (f *namespaceFilter) allowed(namespace string) bool {
+	if f.excluded[namespace] {
+		return false
+	}
+	if len(f.namespaces) != 0 && !f.namespaces[namespace] {
+		return false
+	}
+	if f.selector == nil {
+		return true
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.selected[namespace]
+}
+
+// setSelected records if the namespace matches the namespace label selector,
+// tells if it changed since it was last seen.
+func (f *namespaceFilter) setSelected(namespace string, selected bool) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.selected[namespace] == selected {
+		return false
+	}
+	if selected {
+		f.selected[namespace] = true
+	} else {
+		delete(f.selected, namespace)
+	}
+	return true
+}
+
+// watchedNamespaces returns the namespaces to list and watch resources from,
+// a single empty namespace means all of them.
+func watchedNamespaces(config Config) []string {
+	seen := map[string]bool{}
+	var namespaces []string
+	for _, namespace := range append([]string{config.Namespace}, config.Namespaces...) {
+		if namespace != "" && !seen[namespace] {
+			seen[namespace] = true
+			namespaces = append(namespaces, namespace)
+		}
+	}
+
+	if len(namespaces) == 0 {
+		return []string{metav1.NamespaceAll}
+	}
+	return namespaces
+}
+
+// excludeNamespacesSelector returns the field selector leaving resources from the excluded namespaces out.
+func excludeNamespacesSelector(excluded []string) string {
+	selectors := make([]string, 0, len(excluded))
+	for _, namespace := range excluded {
+		selectors = append(selectors, fmt.Sprintf("metadata.namespace!=%s", fields.EscapeValue(namespace)))
+	}
+	return strings.Join(selectors, ",")
+}
+
+// namespacedEnforcer only enforces source ranges to Services from the namespaces managed by the controller.
+type namespacedEnforcer struct {
+	filter   *namespaceFilter
+	enforcer service.SourceRangeEnforcer
+}
+
+func (n *namespacedEnforcer) EnforceSourceRangesToService(svc *corev1.Service) error {
+	if !n.filter.allowed(svc.ObjectMeta.Namespace) {
+		return nil
+	}
+	return n.enforcer.EnforceSourceRangesToService(svc)
+}
+
+func (n *namespacedEnforcer) ForgetService(namespace, name string) {
+	n.enforcer.ForgetService(namespace, name)
+}
+
+type NamespaceRetriever struct {
+	client   kubernetes.Interface
+	selector string
+}
+
+func NewNamespaceRetriever(client kubernetes.Interface, selector string) *NamespaceRetriever {
+	return &NamespaceRetriever{
+		client:   client,
+		selector: selector,
+	}
+}
+
+func (n *NamespaceRetriever) GetListerWatcher() cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = n.selector
+			return n.client.CoreV1().Namespaces().List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = n.selector
+			return n.client.CoreV1().Namespaces().Watch(options)
+		},
+	}
+}
+
+func (n *NamespaceRetriever) GetObject() runtime.Object {
+	return &corev1.Namespace{}
+}
+
+// namespaceHandler keeps track of the namespaces matching the namespace label selector
+// and enforces source ranges to their Services as soon as they start matching.
+type namespaceHandler struct {
+	sourceRangeEnforcerSrv service.SourceRangeEnforcer
+	index                  *serviceIndex
+	filter                 *namespaceFilter
+	progress               *progressTracker
+}
+
+func (h *namespaceHandler) Add(obj runtime.Object) error {
+	defer h.progress.track()()
+
+	ns, ok := obj.(*corev1.Namespace)
+	if !ok {
+		return fmt.Errorf("%v is not a namespace object", obj.GetObjectKind())
+	}
+
+	selected := h.filter.selector.Matches(labels.Set(ns.ObjectMeta.Labels))
+	if h.filter.setSelected(ns.ObjectMeta.Name, selected) && selected {
+		return enforceServices(h.sourceRangeEnforcerSrv, h.index.namespaceServices(ns.ObjectMeta.Name)...)
+	}
+	return nil
+}
+
+func (h *namespaceHandler) Delete(key string) error {
+	defer h.progress.track()()
+
+	h.filter.setSelected(key, false)
+	return nil
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestNamespaceFilterAllowed(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespaces []string
+		excluded   []string
+		selector   labels.Selector
+		selected   []string
+		allowed    map[string]bool
+	}{
+		{
+			name:       "all namespaces",
+			namespaces: []string{metav1.NamespaceAll},
+			allowed:    map[string]bool{"team-a": true, "kube-system": true},
+		},
+		{
+			name:       "all namespaces but the excluded ones",
+			namespaces: []string{metav1.NamespaceAll},
+			excluded:   []string{"kube-system"},
+			allowed:    map[string]bool{"team-a": true, "kube-system": false},
+		},
+		{
+			name:       "namespace list",
+			namespaces: []string{"team-a", "team-b"},
+			allowed:    map[string]bool{"team-a": true, "team-b": true, "team-c": false},
+		},
+		{
+			name:       "namespace list with an excluded namespace",
+			namespaces: []string{"team-a", "team-b"},
+			excluded:   []string{"team-b"},
+			allowed:    map[string]bool{"team-a": true, "team-b": false},
+		},
+		{
+			name:       "selector",
+			namespaces: []string{metav1.NamespaceAll},
+			selector:   labels.SelectorFromSet(labels.Set{"source-ranges": "enabled"}),
+			selected:   []string{"team-a", "team-b"},
+			allowed:    map[string]bool{"team-a": true, "team-b": true, "team-c": false},
+		},
+		{
+			name:       "selector with a namespace list and an excluded namespace",
+			namespaces: []string{"team-a", "team-b", "team-c"},
+			excluded:   []string{"team-b"},
+			selector:   labels.SelectorFromSet(labels.Set{"source-ranges": "enabled"}),
+			selected:   []string{"team-a", "team-b", "team-d"},
+			allowed:    map[string]bool{"team-a": true, "team-b": false, "team-c": false, "team-d": false},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newNamespaceFilter(test.namespaces, test.excluded, test.selector)
+			for _, namespace := range test.selected {
+				f.setSelected(namespace, true)
+			}
+
+			for namespace, allowed := range test.allowed {
+				assert.Equal(t, allowed, f.allowed(namespace), "namespace %s", namespace)
+			}
+		})
+	}
+}
+
+func TestNamespaceFilterSetSelected(t *testing.T) {
+	f := newNamespaceFilter([]string{metav1.NamespaceAll}, nil, labels.SelectorFromSet(labels.Set{"source-ranges": "enabled"}))
+
+	assert.True(t, f.setSelected("team-a", true))
+	assert.False(t, f.setSelected("team-a", true), "Expected no change when the namespace was already selected")
+	assert.True(t, f.allowed("team-a"))
+
+	assert.True(t, f.setSelected("team-a", false))
+	assert.False(t, f.setSelected("team-a", false), "Expected no change when the namespace was already unselected")
+	assert.False(t, f.allowed("team-a"))
+}
+
+func TestWatchedNamespaces(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		expected []string
+	}{
+		{
+			name:     "all namespaces",
+			expected: []string{metav1.NamespaceAll},
+		},
+		{
+			name:     "namespace",
+			config:   Config{Namespace: "team-a"},
+			expected: []string{"team-a"},
+		},
+		{
+			name:     "namespace list",
+			config:   Config{Namespaces: []string{"team-a", "team-b"}},
+			expected: []string{"team-a", "team-b"},
+		},
+		{
+			name:     "namespace added to the list without duplicates",
+			config:   Config{Namespace: "team-a", Namespaces: []string{"team-b", "team-a"}},
+			expected: []string{"team-a", "team-b"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, watchedNamespaces(test.config))
+		})
+	}
+}
+
+func TestExcludeNamespacesSelector(t *testing.T) {
+	assert.Equal(t, "", excludeNamespacesSelector(nil))
+	assert.Equal(t, "metadata.namespace!=kube-system,metadata.namespace!=kube-public", excludeNamespacesSelector([]string{"kube-system", "kube-public"}))
+}
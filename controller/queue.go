@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/jeffersongirao/source-ranges-controller/log"
+	"github.com/jeffersongirao/source-ranges-controller/metrics"
+	koopermetrics "github.com/spotahome/kooper/monitoring/metrics"
+	kooperhandler "github.com/spotahome/kooper/operator/handler"
+	"github.com/spotahome/kooper/operator/retrieve"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// retryController processes the changes of a resource the same way the kooper generic controller
+// does, recording the same metrics, but retrying the failed changes with a configurable exponential
+// backoff and number of retries instead of kooper's fixed ones.
+type retryController struct {
+	queue        workqueue.RateLimitingInterface
+	informer     cache.SharedIndexInformer
+	handler      kooperhandler.Handler
+	handlerName  string
+	kind         string
+	workers      int
+	maxRetries   int
+	metrics      koopermetrics.Recorder
+	retryMetrics metrics.Recorder
+	logger       log.Logger
+	// running tracks the workers, which finish the change being processed once stopped
+	running sync.WaitGroup
+}
+
+// newRetryController returns a controller processing the changes of the resource with the configured workers and retries.
+func newRetryController(config Config, h kooperhandler.Handler, r retrieve.Retriever, m koopermetrics.Recorder, retryMetrics metrics.Recorder, logger log.Logger) *retryController {
+	workers := config.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Failed changes are retried with an exponential backoff, while the bucket keeps the overall
+	// retries rate under control, like client-go's default controller rate limiter.
+	rateLimiter := workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(config.RetryBaseBackoff, config.RetryMaxBackoff),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+	)
+
+	c := &retryController{
+		queue:        workqueue.NewRateLimitingQueue(rateLimiter),
+		handler:      h,
+		handlerName:  reflect.TypeOf(h).String(),
+		kind:         reflect.Indirect(reflect.ValueOf(r.GetObject())).Type().Name(),
+		workers:      workers,
+		maxRetries:   config.MaxRetries,
+		metrics:      m,
+		retryMetrics: retryMetrics,
+		logger:       logger,
+	}
+
+	c.informer = cache.NewSharedIndexInformer(r.GetListerWatcher(), r.GetObject(), config.ResyncPeriod, cache.Indexers{})
+	c.informer.AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if key, err := cache.MetaNamespaceKeyFunc(obj); err == nil {
+				c.queue.Add(key)
+				c.metrics.IncResourceAddEventQueued(c.handlerName)
+			}
+		},
+		UpdateFunc: func(old interface{}, new interface{}) {
+			if key, err := cache.MetaNamespaceKeyFunc(new); err == nil {
+				c.queue.Add(key)
+				c.metrics.IncResourceAddEventQueued(c.handlerName)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+				c.queue.Add(key)
+				c.metrics.IncResourceDeleteEventQueued(c.handlerName)
+			}
+		},
+	}, config.ResyncPeriod)
+
+	return c
+}
+
+// Run satisfies kooper controller.Controller interface.
+func (c *retryController) Run(stopC <-chan struct{}) error {
+	c.logger.Infof("starting %s controller", c.kind)
+	defer c.queue.ShutDown()
+
+	go c.informer.Run(stopC)
+	if !cache.WaitForCacheSync(stopC, c.informer.HasSynced) {
+		return nil
+	}
+
+	for i := 0; i < c.workers; i++ {
+		c.running.Add(1)
+		go func() {
+			defer c.running.Done()
+			wait.Until(func() { c.runWorker(stopC) }, time.Second, stopC)
+		}()
+	}
+
+	<-stopC
+	c.logger.Infof("stopping %s controller", c.kind)
+	return nil
+}
+
+func (c *retryController) runWorker(stopC <-chan struct{}) {
+	for c.processNextJob(stopC) {
+	}
+}
+
+// wait waits for the workers to exit once Run returned.
+func (c *retryController) wait() {
+	c.running.Wait()
+}
+
+// processNextJob processes the next change of the queue, returning false once the queue is shut down
+// or stopC is closed. The changes left in the queue are processed again on the next start, as the
+// informer lists every resource then.
+func (c *retryController) processNextJob(stopC <-chan struct{}) bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	select {
+	case <-stopC:
+		return false
+	default:
+	}
+
+	err := c.process(key.(string))
+	switch {
+	case err == nil:
+		c.queue.Forget(key)
+	case c.queue.NumRequeues(key) < c.maxRetries:
+		c.logger.Warningf("error processing %s %s (retry %d of %d): %v", c.kind, key, c.queue.NumRequeues(key)+1, c.maxRetries, err)
+		c.queue.AddRateLimited(key)
+	default:
+		c.logger.Errorf("error processing %s %s, giving up after %d retries until the next resync: %v", c.kind, key, c.maxRetries, err)
+		if failed, ok := err.(*servicesError); ok {
+			for _, svc := range failed.services {
+				c.retryMetrics.IncRetriesExhausted(c.kind, svc.ObjectMeta.Namespace, svc.ObjectMeta.Name)
+			}
+		}
+		c.queue.Forget(key)
+	}
+	return true
+}
+
+func (c *retryController) process(key string) error {
+	obj, exists, err := c.informer.GetIndexer().GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if !exists {
+		if err := c.handler.Delete(key); err != nil {
+			c.metrics.IncResourceDeleteEventProcessedError(c.handlerName)
+			c.metrics.ObserveDurationResourceDeleteEventProcessedError(c.handlerName, start)
+			return err
+		}
+		c.metrics.IncResourceDeleteEventProcessedSuccess(c.handlerName)
+		c.metrics.ObserveDurationResourceDeleteEventProcessedSuccess(c.handlerName, start)
+		return nil
+	}
+
+	if err := c.handler.Add(obj.(runtime.Object)); err != nil {
+		c.metrics.IncResourceAddEventProcessedError(c.handlerName)
+		c.metrics.ObserveDurationResourceAddEventProcessedError(c.handlerName, start)
+		return err
+	}
+	c.metrics.IncResourceAddEventProcessedSuccess(c.handlerName)
+	c.metrics.ObserveDurationResourceAddEventProcessedSuccess(c.handlerName, start)
+	return nil
+}
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeffersongirao/source-ranges-controller/log"
+	"github.com/jeffersongirao/source-ranges-controller/metrics"
+	koopermetrics "github.com/spotahome/kooper/monitoring/metrics"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// failingHandler fails enforcing the first changes it handles
+type failingHandler struct {
+	failures int
+	calls    int
+}
+
+func (h *failingHandler) Add(obj runtime.Object) error {
+	h.calls++
+	if h.calls <= h.failures {
+		return &servicesError{
+			services: []*corev1.Service{obj.(*corev1.Service)},
+			err:      fmt.Errorf("failure %d", h.calls),
+		}
+	}
+	return nil
+}
+
+func (h *failingHandler) Delete(_ string) error {
+	return nil
+}
+
+// fakeRetryMetrics records the exhausted retries
+type fakeRetryMetrics struct {
+	metrics.Recorder
+
+	mu        sync.Mutex
+	exhausted []string
+}
+
+func (m *fakeRetryMetrics) IncRetriesExhausted(kind string, namespace string, service string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.exhausted = append(m.exhausted, kind+"/"+namespace+"/"+service)
+}
+
+// newTestRetryController returns a retry controller with the Service in its cache and queue, the
+// informer isn't run so the tests process the queue one change at a time.
+func newTestRetryController(h *failingHandler, m *fakeRetryMetrics) *retryController {
+	config := Config{
+		MaxRetries:       2,
+		RetryBaseBackoff: time.Millisecond,
+		RetryMaxBackoff:  10 * time.Millisecond,
+	}
+	r := NewServiceRetriever(fake.NewSimpleClientset(), metav1.NamespaceAll, nil)
+	c := newRetryController(config, h, r, koopermetrics.Dummy, m, log.Dummy)
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Name:      "test-service",
+		},
+	}
+	c.informer.GetIndexer().Add(svc)
+	c.queue.Add("default/test-service")
+	return c
+}
+
+func TestRetryControllerForgetsOnSuccess(t *testing.T) {
+	h := &failingHandler{failures: 2}
+	m := &fakeRetryMetrics{}
+	c := newTestRetryController(h, m)
+	stopC := make(chan struct{})
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, c.processNextJob(stopC))
+	}
+
+	assert.Equal(t, 3, h.calls)
+	assert.Equal(t, 0, c.queue.NumRequeues("default/test-service"))
+	assert.Equal(t, 0, c.queue.Len())
+	assert.Empty(t, m.exhausted)
+}
+
+func TestRetryControllerStopsAtMaxRetries(t *testing.T) {
+	h := &failingHandler{failures: 10}
+	m := &fakeRetryMetrics{}
+	c := newTestRetryController(h, m)
+	stopC := make(chan struct{})
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, c.processNextJob(stopC))
+	}
+
+	assert.Equal(t, 3, h.calls)
+	assert.Equal(t, 0, c.queue.NumRequeues("default/test-service"))
+	assert.Equal(t, []string{"Service/default/test-service"}, m.exhausted)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 0, c.queue.Len(), "Expected no retry after giving up")
+}
+
+func TestRetryControllerStopsTakingChangesOnceStopped(t *testing.T) {
+	h := &failingHandler{}
+	m := &fakeRetryMetrics{}
+	c := newTestRetryController(h, m)
+	stopC := make(chan struct{})
+	close(stopC)
+
+	assert.False(t, c.processNextJob(stopC))
+	assert.Equal(t, 0, h.calls)
+}
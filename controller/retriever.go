@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"github.com/jeffersongirao/source-ranges-controller/apis/sourceranges/v1alpha1"
+	"github.com/jeffersongirao/source-ranges-controller/client"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -10,23 +12,27 @@ import (
 )
 
 type ServiceRetriever struct {
-	client    kubernetes.Interface
-	namespace string
+	client        kubernetes.Interface
+	namespace     string
+	fieldSelector string
 }
 
-func NewServiceRetriever(client kubernetes.Interface, namespace string) *ServiceRetriever {
+func NewServiceRetriever(client kubernetes.Interface, namespace string, excludeNamespaces []string) *ServiceRetriever {
 	return &ServiceRetriever{
-		client:    client,
-		namespace: namespace,
+		client:        client,
+		namespace:     namespace,
+		fieldSelector: excludeNamespacesSelector(excludeNamespaces),
 	}
 }
 
 func (s *ServiceRetriever) GetListerWatcher() cache.ListerWatcher {
 	return &cache.ListWatch{
 		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = s.fieldSelector
 			return s.client.CoreV1().Services(s.namespace).List(options)
 		},
 		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = s.fieldSelector
 			return s.client.CoreV1().Services(s.namespace).Watch(options)
 		},
 	}
@@ -35,3 +41,90 @@ func (s *ServiceRetriever) GetListerWatcher() cache.ListerWatcher {
 func (s *ServiceRetriever) GetObject() runtime.Object {
 	return &corev1.Service{}
 }
+
+type ConfigMapRetriever struct {
+	client        kubernetes.Interface
+	namespace     string
+	fieldSelector string
+}
+
+func NewConfigMapRetriever(client kubernetes.Interface, namespace string, excludeNamespaces []string) *ConfigMapRetriever {
+	return &ConfigMapRetriever{
+		client:        client,
+		namespace:     namespace,
+		fieldSelector: excludeNamespacesSelector(excludeNamespaces),
+	}
+}
+
+func (c *ConfigMapRetriever) GetListerWatcher() cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = c.fieldSelector
+			return c.client.CoreV1().ConfigMaps(c.namespace).List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = c.fieldSelector
+			return c.client.CoreV1().ConfigMaps(c.namespace).Watch(options)
+		},
+	}
+}
+
+func (c *ConfigMapRetriever) GetObject() runtime.Object {
+	return &corev1.ConfigMap{}
+}
+
+type SourceRangeSetRetriever struct {
+	client        client.Interface
+	namespace     string
+	fieldSelector string
+}
+
+func NewSourceRangeSetRetriever(client client.Interface, namespace string, excludeNamespaces []string) *SourceRangeSetRetriever {
+	return &SourceRangeSetRetriever{
+		client:        client,
+		namespace:     namespace,
+		fieldSelector: excludeNamespacesSelector(excludeNamespaces),
+	}
+}
+
+func (s *SourceRangeSetRetriever) GetListerWatcher() cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = s.fieldSelector
+			return s.client.SourceRangeSets(s.namespace).List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = s.fieldSelector
+			return s.client.SourceRangeSets(s.namespace).Watch(options)
+		},
+	}
+}
+
+func (s *SourceRangeSetRetriever) GetObject() runtime.Object {
+	return &v1alpha1.SourceRangeSet{}
+}
+
+type ClusterSourceRangeSetRetriever struct {
+	client client.Interface
+}
+
+func NewClusterSourceRangeSetRetriever(client client.Interface) *ClusterSourceRangeSetRetriever {
+	return &ClusterSourceRangeSetRetriever{
+		client: client,
+	}
+}
+
+func (c *ClusterSourceRangeSetRetriever) GetListerWatcher() cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return c.client.ClusterSourceRangeSets().List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return c.client.ClusterSourceRangeSets().Watch(options)
+		},
+	}
+}
+
+func (c *ClusterSourceRangeSetRetriever) GetObject() runtime.Object {
+	return &v1alpha1.ClusterSourceRangeSet{}
+}
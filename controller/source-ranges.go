@@ -2,72 +2,425 @@ package controller
 
 import (
 	"fmt"
-	"net/http"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/jeffersongirao/source-ranges-controller/apis/sourceranges/v1alpha1"
+	"github.com/jeffersongirao/source-ranges-controller/client"
 	"github.com/jeffersongirao/source-ranges-controller/eventer"
 	"github.com/jeffersongirao/source-ranges-controller/log"
+	"github.com/jeffersongirao/source-ranges-controller/metrics"
 	"github.com/jeffersongirao/source-ranges-controller/service"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/spotahome/kooper/monitoring/metrics"
+	koopermetrics "github.com/spotahome/kooper/monitoring/metrics"
 	"github.com/spotahome/kooper/operator/controller"
+	kooperhandler "github.com/spotahome/kooper/operator/handler"
+	"github.com/spotahome/kooper/operator/retrieve"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 )
 
 type Controller struct {
-	controller.Controller
-	config Config
+	controllers []*retryController
+	config      Config
+	metrics     metrics.Recorder
+	progress    *progressTracker
+	recorder    *eventer.EventRecorder
 }
 
 const (
 	metricsPrefix = "source_ranges"
 	eventsPrefix  = "source-ranges-controller"
-	metricsAddr   = ":7777"
 )
 
-func createPrometheusRecorder(logger log.Logger) metrics.Recorder {
-	reg := prometheus.NewRegistry()
-	m := metrics.NewPrometheus(metricsPrefix, reg)
+func createPrometheusRecorders(reg prometheus.Registerer) (koopermetrics.Recorder, metrics.Recorder) {
+	m := koopermetrics.NewPrometheus(metricsPrefix, reg)
+	em := metrics.NewPrometheus(metricsPrefix, reg)
 
-	h := promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
-	go func() {
-		logger.Infof("serving metrics at %s", metricsAddr)
-		http.ListenAndServe(metricsAddr, h)
-	}()
-
-	return m
+	return m, em
 }
 
-func New(config Config, k8sCli kubernetes.Interface, logger log.Logger) (*Controller, error) {
+func New(config Config, k8sCli kubernetes.Interface, srsCli client.Interface, reg prometheus.Registerer, logger log.Logger) (*Controller, error) {
+	emptyPolicy, err := service.ParseEmptySourceRangesPolicy(config.EmptySourceRangesPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	var selector labels.Selector
+	if config.NamespaceSelector != "" {
+		if selector, err = labels.Parse(config.NamespaceSelector); err != nil {
+			return nil, fmt.Errorf("invalid namespace selector: %v", err)
+		}
+	}
+
 	recorder := eventer.NewEventRecorder(k8sCli, logger, eventsPrefix)
-	sourceRangeEnforcer := service.NewConfigMapSourceRangeEnforcer(k8sCli, recorder)
-	handler := &handler{sourceRangeEnforcerSrv: sourceRangeEnforcer}
-	retriever := NewServiceRetriever(k8sCli, config.Namespace)
-	m := createPrometheusRecorder(logger)
-	ctrl := controller.NewSequential(config.ResyncPeriod, handler, retriever, m, logger)
+	m, em := createPrometheusRecorders(reg)
+	index := newServiceIndex(k8sCli, srsCli)
+	enforcerCfg := service.Config{
+		ConfigMapGetter:         index,
+		Aggregate:               config.AggregateSourceRanges,
+		MaxSourceRanges:         config.MaxSourceRanges,
+		EmptySourceRangesPolicy: emptyPolicy,
+		MetricsRecorder:         em,
+		DryRun:                  config.DryRun,
+		Logger:                  logger,
+	}
+	if config.SourceRangeSets {
+		enforcerCfg.SourceRangeSetGetter = index
+	}
+	namespaces := watchedNamespaces(config)
+	filter := newNamespaceFilter(namespaces, config.ExcludeNamespaces, selector)
+
+	sourceRangeEnforcer := newKeyedEnforcer(&namespacedEnforcer{
+		filter:   filter,
+		enforcer: service.NewConfigMapSourceRangeEnforcerWithConfig(enforcerCfg, k8sCli, recorder),
+	})
+
+	progress := newProgressTracker()
+	var resources []resource
+	for _, namespace := range namespaces {
+		var excluded []string
+		if namespace == metav1.NamespaceAll {
+			excluded = config.ExcludeNamespaces
+		}
+
+		svcHandler := &handler{sourceRangeEnforcerSrv: sourceRangeEnforcer, index: index, progress: progress}
+		svcRetriever := NewServiceRetriever(k8sCli, namespace, excluded)
+
+		cmHandler := &configMapHandler{sourceRangeEnforcerSrv: sourceRangeEnforcer, index: index, progress: progress}
+		cmRetriever := NewConfigMapRetriever(k8sCli, namespace, excluded)
+
+		resources = append(resources, resource{svcHandler, svcRetriever}, resource{cmHandler, cmRetriever})
+
+		if config.SourceRangeSets {
+			setHandler := &sourceRangeSetHandler{sourceRangeEnforcerSrv: sourceRangeEnforcer, index: index, progress: progress, client: srsCli}
+			setRetriever := NewSourceRangeSetRetriever(srsCli, namespace, excluded)
+
+			resources = append(resources, resource{setHandler, setRetriever})
+		}
+	}
+
+	if config.SourceRangeSets {
+		clusterSetHandler := &clusterSourceRangeSetHandler{sourceRangeEnforcerSrv: sourceRangeEnforcer, index: index, progress: progress, client: srsCli}
+		clusterSetRetriever := NewClusterSourceRangeSetRetriever(srsCli)
+
+		resources = append(resources, resource{clusterSetHandler, clusterSetRetriever})
+	}
+
+	if selector != nil {
+		nsHandler := &namespaceHandler{sourceRangeEnforcerSrv: sourceRangeEnforcer, index: index, progress: progress, filter: filter}
+		nsRetriever := NewNamespaceRetriever(k8sCli, config.NamespaceSelector)
+
+		resources = append(resources, resource{nsHandler, nsRetriever})
+	}
+
+	controllers := make([]*retryController, 0, len(resources))
+	for _, r := range resources {
+		controllers = append(controllers, newRetryController(config, r.handler, r.retriever, m, em, logger))
+	}
 
 	return &Controller{
-		Controller: ctrl,
-		config:     config,
+		controllers: controllers,
+		config:      config,
+		metrics:     em,
+		progress:    progress,
+		recorder:    recorder,
 	}, nil
 }
 
+// MetricsRecorder returns the recorder of the controller metrics.
+func (c *Controller) MetricsRecorder() metrics.Recorder {
+	return c.metrics
+}
+
+// Run starts the Service controller and the controllers of the sources Services reference
+// and blocks until stopC is closed or one of them fails, stopping the others then.
+func (c *Controller) Run(stopC <-chan struct{}) error {
+	ctrlStopC := make(chan struct{})
+	errC := make(chan error, len(c.controllers))
+	for _, ctrl := range c.controllers {
+		go func(ctrl controller.Controller) {
+			errC <- ctrl.Run(ctrlStopC)
+		}(ctrl)
+	}
+
+	var err error
+	pending := len(c.controllers)
+	select {
+	case <-stopC:
+	case err = <-errC:
+		pending--
+	}
+	close(ctrlStopC)
+	for ; pending > 0; pending-- {
+		if ctrlErr := <-errC; err == nil {
+			err = ctrlErr
+		}
+	}
+	return err
+}
+
+// Drain waits for the workers to finish the changes being processed when Run returned and
+// sends the pending events, giving up after the timeout.
+func (c *Controller) Drain(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	doneC := make(chan struct{})
+	go func() {
+		defer close(doneC)
+		for _, ctrl := range c.controllers {
+			ctrl.wait()
+		}
+	}()
+	select {
+	case <-doneC:
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out waiting for the changes being processed")
+	}
+
+	return c.recorder.Flush(deadline.Sub(time.Now()))
+}
+
+// resource is a kind of resource the controller watches along with the handler of its changes.
+type resource struct {
+	handler   kooperhandler.Handler
+	retriever retrieve.Retriever
+}
+
+// keyedEnforcer makes sure a Service is never enforced by two workers at once, as
+// Services are enforced concurrently from the Service and the sources controllers.
+type keyedEnforcer struct {
+	mu       sync.Mutex
+	locks    map[string]*keyLock
+	enforcer service.SourceRangeEnforcer
+}
+
+// keyLock is the lock of a Service along with the number of workers holding or waiting for it.
+type keyLock struct {
+	sync.Mutex
+	users int
+}
+
+func newKeyedEnforcer(enforcer service.SourceRangeEnforcer) *keyedEnforcer {
+	return &keyedEnforcer{
+		locks:    map[string]*keyLock{},
+		enforcer: enforcer,
+	}
+}
+
+func (k *keyedEnforcer) EnforceSourceRangesToService(svc *corev1.Service) error {
+	key := svc.ObjectMeta.Namespace + "/" + svc.ObjectMeta.Name
+	lock := k.lock(key)
+	defer k.unlock(key, lock)
+
+	return k.enforcer.EnforceSourceRangesToService(svc)
+}
+
+func (k *keyedEnforcer) ForgetService(namespace, name string) {
+	key := namespace + "/" + name
+	lock := k.lock(key)
+	defer k.unlock(key, lock)
+
+	k.enforcer.ForgetService(namespace, name)
+}
+
+func (k *keyedEnforcer) lock(key string) *keyLock {
+	k.mu.Lock()
+	lock, ok := k.locks[key]
+	if !ok {
+		lock = &keyLock{}
+		k.locks[key] = lock
+	}
+	lock.users++
+	k.mu.Unlock()
+
+	lock.Lock()
+	return lock
+}
+
+func (k *keyedEnforcer) unlock(key string, lock *keyLock) {
+	lock.Unlock()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	lock.users--
+	if lock.users == 0 {
+		delete(k.locks, key)
+	}
+}
+
 type handler struct {
 	sourceRangeEnforcerSrv service.SourceRangeEnforcer
+	index                  *serviceIndex
+	progress               *progressTracker
 }
 
 func (h *handler) Add(obj runtime.Object) error {
+	defer h.progress.track()()
+
 	svc, ok := obj.(*corev1.Service)
 	if !ok {
 		return fmt.Errorf("%v is not a service object", obj.GetObjectKind())
 	}
 
-	h.sourceRangeEnforcerSrv.EnforceSourceRangesToService(svc)
+	h.index.setService(svc)
+	return enforceServices(h.sourceRangeEnforcerSrv, svc)
+}
+
+func (h *handler) Delete(key string) error {
+	defer h.progress.track()()
+
+	h.index.deleteService(key)
+	if namespace, name, err := cache.SplitMetaNamespaceKey(key); err == nil {
+		h.sourceRangeEnforcerSrv.ForgetService(namespace, name)
+	}
 	return nil
 }
 
-func (h *handler) Delete(string) error {
+// configMapHandler enforces source ranges to the Services referencing a ConfigMap as soon as it changes.
+type configMapHandler struct {
+	sourceRangeEnforcerSrv service.SourceRangeEnforcer
+	index                  *serviceIndex
+	progress               *progressTracker
+}
+
+func (h *configMapHandler) Add(obj runtime.Object) error {
+	defer h.progress.track()()
+
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		return fmt.Errorf("%v is not a configmap object", obj.GetObjectKind())
+	}
+
+	return enforceServices(h.sourceRangeEnforcerSrv, h.index.setSource(configMapKind, cm)...)
+}
+
+func (h *configMapHandler) Delete(key string) error {
+	defer h.progress.track()()
+
+	return enforceServices(h.sourceRangeEnforcerSrv, h.index.deleteSource(configMapKind, key)...)
+}
+
+// sourceRangeSetHandler enforces source ranges to the Services referencing a SourceRangeSet
+// as soon as it changes and keeps its status up to date.
+type sourceRangeSetHandler struct {
+	sourceRangeEnforcerSrv service.SourceRangeEnforcer
+	index                  *serviceIndex
+	client                 client.Interface
+	progress               *progressTracker
+}
+
+func (h *sourceRangeSetHandler) Add(obj runtime.Object) error {
+	defer h.progress.track()()
+
+	set, ok := obj.(*v1alpha1.SourceRangeSet)
+	if !ok {
+		return fmt.Errorf("%v is not a sourcerangeset object", obj.GetObjectKind())
+	}
+
+	enforceErr := enforceServices(h.sourceRangeEnforcerSrv, h.index.setSource(sourceRangeSetKind, set)...)
+
+	status := sourceRangeSetStatus(set.Spec, h.index.consumers(sourceRangeSetKind, set.Namespace+"/"+set.Name))
+	if status != set.Status {
+		updated := set.DeepCopy()
+		updated.Status = status
+		// The enforcement error takes precedence, the status is updated again when retrying it.
+		if _, err := h.client.SourceRangeSets(set.Namespace).UpdateStatus(updated); err != nil && enforceErr == nil {
+			return err
+		}
+	}
+	return enforceErr
+}
+
+func (h *sourceRangeSetHandler) Delete(key string) error {
+	defer h.progress.track()()
+
+	return enforceServices(h.sourceRangeEnforcerSrv, h.index.deleteSource(sourceRangeSetKind, key)...)
+}
+
+// clusterSourceRangeSetHandler enforces source ranges to the Services referencing a ClusterSourceRangeSet
+// as soon as it changes and keeps its status up to date.
+type clusterSourceRangeSetHandler struct {
+	sourceRangeEnforcerSrv service.SourceRangeEnforcer
+	index                  *serviceIndex
+	client                 client.Interface
+	progress               *progressTracker
+}
+
+func (h *clusterSourceRangeSetHandler) Add(obj runtime.Object) error {
+	defer h.progress.track()()
+
+	set, ok := obj.(*v1alpha1.ClusterSourceRangeSet)
+	if !ok {
+		return fmt.Errorf("%v is not a clustersourcerangeset object", obj.GetObjectKind())
+	}
+
+	enforceErr := enforceServices(h.sourceRangeEnforcerSrv, h.index.setSource(clusterSourceRangeSetKind, set)...)
+
+	status := sourceRangeSetStatus(set.Spec, h.index.consumers(clusterSourceRangeSetKind, set.Name))
+	if status != set.Status {
+		updated := set.DeepCopy()
+		updated.Status = status
+		// The enforcement error takes precedence, the status is updated again when retrying it.
+		if _, err := h.client.ClusterSourceRangeSets().UpdateStatus(updated); err != nil && enforceErr == nil {
+			return err
+		}
+	}
+	return enforceErr
+}
+
+func (h *clusterSourceRangeSetHandler) Delete(key string) error {
+	defer h.progress.track()()
+
+	return enforceServices(h.sourceRangeEnforcerSrv, h.index.deleteSource(clusterSourceRangeSetKind, key)...)
+}
+
+// enforceServices enforces source ranges to the Services, returning an error when any of them failed
+// with a transient error so the change is retried. Permanent and policy errors are only retried
+// once the inputs change, as retrying them right away can't succeed.
+func enforceServices(enforcer service.SourceRangeEnforcer, svcs ...*corev1.Service) error {
+	var failed []*corev1.Service
+	var keys []string
+	var lastErr error
+	for _, svc := range svcs {
+		err := enforcer.EnforceSourceRangesToService(svc)
+		if err != nil && service.ReasonOf(err) == service.ErrorReasonTransient {
+			failed = append(failed, svc)
+			keys = append(keys, svc.ObjectMeta.Namespace+"/"+svc.ObjectMeta.Name)
+			lastErr = err
+		}
+	}
+
+	if len(failed) != 0 {
+		return &servicesError{
+			services: failed,
+			err:      fmt.Errorf("could not enforce source ranges to Services %s: %v", strings.Join(keys, ", "), lastErr),
+		}
+	}
 	return nil
 }
+
+// servicesError is the error enforcing source ranges to some Services along with those Services,
+// so the ones left unenforced once the retries are exhausted can be told.
+type servicesError struct {
+	services []*corev1.Service
+	err      error
+}
+
+func (e *servicesError) Error() string {
+	return e.err.Error()
+}
+
+func sourceRangeSetStatus(spec v1alpha1.SourceRangeSetSpec, consumers int) v1alpha1.SourceRangeSetStatus {
+	status := v1alpha1.SourceRangeSetStatus{Consumers: consumers}
+	if err := spec.Validate(); err != nil {
+		status.LastValidationError = err.Error()
+	}
+	return status
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// concurrencyEnforcer records the most enforcements of a Service running at once
+type concurrencyEnforcer struct {
+	mu      sync.Mutex
+	running map[string]int
+	max     map[string]int
+}
+
+func (e *concurrencyEnforcer) EnforceSourceRangesToService(svc *corev1.Service) error {
+	key := svc.ObjectMeta.Namespace + "/" + svc.ObjectMeta.Name
+	e.enter(key)
+	time.Sleep(time.Millisecond)
+	e.leave(key)
+	return nil
+}
+
+func (e *concurrencyEnforcer) ForgetService(namespace, name string) {
+	key := namespace + "/" + name
+	e.enter(key)
+	e.leave(key)
+}
+
+func (e *concurrencyEnforcer) enter(key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.running[key]++
+	if e.running[key] > e.max[key] {
+		e.max[key] = e.running[key]
+	}
+}
+
+func (e *concurrencyEnforcer) leave(key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.running[key]--
+}
+
+func TestKeyedEnforcerSerializesServices(t *testing.T) {
+	enforcer := &concurrencyEnforcer{running: map[string]int{}, max: map[string]int{}}
+	k := newKeyedEnforcer(enforcer)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		svc := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: metav1.NamespaceDefault,
+				Name:      fmt.Sprintf("test-service-%d", i%2),
+			},
+		}
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			k.EnforceSourceRangesToService(svc)
+		}()
+		go func() {
+			defer wg.Done()
+			k.ForgetService(svc.ObjectMeta.Namespace, svc.ObjectMeta.Name)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[string]int{"default/test-service-0": 1, "default/test-service-1": 1}, enforcer.max)
+	assert.Empty(t, k.locks, "Expected the locks to be released once no worker holds them")
+}
+
+// failingEnforcer fails enforcing the given Services with the given error
+type failingEnforcer struct {
+	failing map[string]error
+}
+
+func (e *failingEnforcer) EnforceSourceRangesToService(svc *corev1.Service) error {
+	return e.failing[svc.ObjectMeta.Name]
+}
+
+func (e *failingEnforcer) ForgetService(_, _ string) {}
+
+func TestEnforceServicesReturnsTheFailingServices(t *testing.T) {
+	var svcs []*corev1.Service
+	for _, name := range []string{"ok", "failing"} {
+		svcs = append(svcs, &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: metav1.NamespaceDefault, Name: name},
+		})
+	}
+	enforcer := &failingEnforcer{failing: map[string]error{
+		"failing": fmt.Errorf("API server down"),
+	}}
+
+	err := enforceServices(enforcer, svcs...)
+	if assert.IsType(t, &servicesError{}, err) {
+		assert.Equal(t, []*corev1.Service{svcs[1]}, err.(*servicesError).services)
+		assert.EqualError(t, err, "could not enforce source ranges to Services default/failing: API server down")
+	}
+	assert.NoError(t, enforceServices(enforcer, svcs[0]))
+}
@@ -1,21 +1,106 @@
 package eventer
 
 import (
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/jeffersongirao/source-ranges-controller/log"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/tools/reference"
 )
 
-func NewEventRecorder(client kubernetes.Interface, logger log.Logger, component string) record.EventRecorder {
+// EventRecorder records events to the API server in the background and keeps track of
+// the ones not sent yet, so they can be flushed before the controller exits.
+type EventRecorder struct {
+	recorder record.EventRecorder
+	logger   log.Logger
+
+	mu      sync.RWMutex
+	closed  bool
+	pending sync.WaitGroup
+}
+
+func NewEventRecorder(client kubernetes.Interface, logger log.Logger, component string) *EventRecorder {
+	e := &EventRecorder{logger: logger}
+
 	broadcaster := record.NewBroadcaster()
 	broadcaster.StartEventWatcher(
 		func(event *corev1.Event) {
+			defer e.pending.Done()
 			if _, err := client.CoreV1().Events(event.Namespace).Create(event); err != nil {
 				logger.Errorf("%v\n", err)
 			}
 		},
 	)
-	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: component})
+	e.recorder = broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: component})
+	return e
+}
+
+// Event satisfies record.EventRecorder interface.
+func (e *EventRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	if e.add(object, eventtype) {
+		e.recorder.Event(object, eventtype, reason, message)
+	}
+}
+
+// Eventf satisfies record.EventRecorder interface.
+func (e *EventRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	if e.add(object, eventtype) {
+		e.recorder.Eventf(object, eventtype, reason, messageFmt, args...)
+	}
+}
+
+// PastEventf satisfies record.EventRecorder interface.
+func (e *EventRecorder) PastEventf(object runtime.Object, timestamp metav1.Time, eventtype, reason, messageFmt string, args ...interface{}) {
+	if e.add(object, eventtype) {
+		e.recorder.PastEventf(object, timestamp, eventtype, reason, messageFmt, args...)
+	}
+}
+
+// add counts an event about to be recorded, unless the recorder was flushed or the
+// event is one the broadcaster drops.
+func (e *EventRecorder) add(object runtime.Object, eventtype string) bool {
+	if eventtype != corev1.EventTypeNormal && eventtype != corev1.EventTypeWarning {
+		return false
+	}
+	if _, err := reference.GetReference(scheme.Scheme, object); err != nil {
+		e.logger.Errorf("dropping event about an object that can't be referenced: %v", err)
+		return false
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.closed {
+		e.logger.Warningf("dropping event recorded after the event recorder was flushed")
+		return false
+	}
+	e.pending.Add(1)
+	return true
+}
+
+// Flush stops recording new events and waits until the recorded ones are sent, giving up after the timeout.
+func (e *EventRecorder) Flush(timeout time.Duration) error {
+	e.mu.Lock()
+	e.closed = true
+	e.mu.Unlock()
+
+	sent := make(chan struct{})
+	go func() {
+		e.pending.Wait()
+		close(sent)
+	}()
+
+	select {
+	case <-sent:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out sending the pending events")
+	}
 }
@@ -0,0 +1,38 @@
+package eventer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jeffersongirao/source-ranges-controller/eventer"
+	"github.com/jeffersongirao/source-ranges-controller/log"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFlushSendsPendingEvents(t *testing.T) {
+	k8sCli := fake.NewSimpleClientset()
+	recorder := eventer.NewEventRecorder(k8sCli, log.Dummy, "test")
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Name:      "test-service",
+			SelfLink:  "/api/v1/namespaces/default/services/test-service",
+		},
+	}
+	for i := 0; i < 10; i++ {
+		recorder.Eventf(svc, corev1.EventTypeNormal, "Test", "event %d", i)
+	}
+
+	err := recorder.Flush(5 * time.Second)
+	assert.Nil(t, err)
+
+	events, _ := k8sCli.CoreV1().Events(metav1.NamespaceDefault).List(metav1.ListOptions{})
+	assert.Len(t, events.Items, 10)
+
+	recorder.Event(svc, corev1.EventTypeNormal, "Test", "recorded after flushing")
+	assert.Nil(t, recorder.Flush(time.Second))
+}
@@ -0,0 +1,79 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Check tells if a part of the controller is healthy, returning why when it isn't
+type Check func() error
+
+// Checks serves the liveness and readiness checks of the controller
+type Checks struct {
+	mu        sync.RWMutex
+	liveness  map[string]Check
+	readiness map[string]Check
+}
+
+// NewChecks returns a new Checks without any check, which is both alive and ready
+func NewChecks() *Checks {
+	return &Checks{
+		liveness:  map[string]Check{},
+		readiness: map[string]Check{},
+	}
+}
+
+// AddLivenessCheck adds a check that must pass for the controller to be considered alive
+func (c *Checks) AddLivenessCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.liveness[name] = check
+}
+
+// AddReadinessCheck adds a check that must pass for the controller to be considered ready
+func (c *Checks) AddReadinessCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readiness[name] = check
+}
+
+// LivenessHandler serves the liveness checks, answering 503 when any of them fails
+func (c *Checks) LivenessHandler() http.Handler {
+	return c.handler(func() map[string]Check { return c.liveness })
+}
+
+// ReadinessHandler serves the readiness checks, answering 503 when any of them fails
+func (c *Checks) ReadinessHandler() http.Handler {
+	return c.handler(func() map[string]Check { return c.readiness })
+}
+
+func (c *Checks) handler(checks func() map[string]Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.mu.RLock()
+		names := make([]string, 0, len(checks()))
+		for name := range checks() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		status := http.StatusOK
+		var body string
+		for _, name := range names {
+			if err := checks()[name](); err != nil {
+				status = http.StatusServiceUnavailable
+				body += fmt.Sprintf("[-] %s failed: %v\n", name, err)
+			} else {
+				body += fmt.Sprintf("[+] %s ok\n", name)
+			}
+		}
+		c.mu.RUnlock()
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	})
+}
@@ -0,0 +1,35 @@
+package health_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jeffersongirao/source-ranges-controller/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	checks := health.NewChecks()
+	checks.AddReadinessCheck("caches", func() error { return fmt.Errorf("waiting for caches to sync") })
+	checks.AddReadinessCheck("leader-election", func() error { return nil })
+
+	w := httptest.NewRecorder()
+	checks.ReadinessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "[-] caches failed: waiting for caches to sync\n[+] leader-election ok\n", w.Body.String())
+}
+
+func TestLivenessHandler(t *testing.T) {
+	checks := health.NewChecks()
+	checks.AddLivenessCheck("progress", func() error { return nil })
+	checks.AddReadinessCheck("caches", func() error { return fmt.Errorf("waiting for caches to sync") })
+
+	w := httptest.NewRecorder()
+	checks.LivenessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[+] progress ok\n", w.Body.String())
+}
@@ -0,0 +1,328 @@
+package leaderelection
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jeffersongirao/source-ranges-controller/log"
+	"github.com/jeffersongirao/source-ranges-controller/metrics"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// The annotation holding the leader election record, the same one client-go uses for ConfigMap locks
+	leaderAnnotationKey = "control-plane.alpha.kubernetes.io/leader"
+)
+
+// Config is the leader election configuration
+type Config struct {
+	// Namespace and Name of the ConfigMap used as lock
+	Namespace string
+	Name      string
+	// Identity of this candidate, unique among the replicas
+	Identity string
+	// LeaseDuration is how long standbys wait before taking over a leadership that isn't renewed
+	LeaseDuration time.Duration
+	// RenewDeadline is how long the leader keeps trying to renew its leadership before giving up
+	RenewDeadline time.Duration
+	// RetryPeriod is how long candidates wait between tries
+	RetryPeriod time.Duration
+}
+
+// LeadStopTimeout is how long lead can take to return once told to stop without a standby leading
+// alongside it. A lost leadership is noticed up to RetryPeriod after RenewDeadline has passed since
+// the last renewal, and standbys take over once LeaseDuration has passed since then.
+func (c Config) LeadStopTimeout() time.Duration {
+	return c.LeaseDuration - c.RenewDeadline - c.RetryPeriod
+}
+
+// Validate tells if the configuration leaves lead any time to stop before a standby takes over
+func (c Config) Validate() error {
+	if c.LeadStopTimeout() <= 0 {
+		return fmt.Errorf("the lease duration must be longer than the renew deadline plus the retry period")
+	}
+	return nil
+}
+
+// record is the leader election record kept in the lock ConfigMap
+type record struct {
+	HolderIdentity       string      `json:"holderIdentity"`
+	LeaseDurationSeconds int         `json:"leaseDurationSeconds"`
+	AcquireTime          metav1.Time `json:"acquireTime"`
+	RenewTime            metav1.Time `json:"renewTime"`
+	LeaderTransitions    int         `json:"leaderTransitions"`
+}
+
+// Elector elects a leader among the controller replicas using a ConfigMap as lock
+type Elector struct {
+	client  kubernetes.Interface
+	config  Config
+	metrics metrics.Recorder
+	logger  log.Logger
+
+	observedRecord record
+	observedTime   time.Time
+	now            func() time.Time
+
+	mu        sync.RWMutex
+	roleKnown bool
+	leading   bool
+}
+
+// New returns a new Elector
+func New(config Config, k8sCli kubernetes.Interface, metricsRecorder metrics.Recorder, logger log.Logger) *Elector {
+	return &Elector{
+		client:  k8sCli,
+		config:  config,
+		metrics: metricsRecorder,
+		logger:  logger,
+		now:     time.Now,
+	}
+}
+
+// Run waits until this candidate is elected and runs lead until it returns or the leadership is lost,
+// the channel given to lead is closed as soon as the leadership is lost or stopC is closed. lead must
+// return within the configured LeadStopTimeout then, the leadership is released once it returned so a
+// standby can take over right away. Otherwise, or when lead failed, the lease is left to expire.
+func (e *Elector) Run(stopC <-chan struct{}, lead func(stopC <-chan struct{}) error) error {
+	if !e.acquire(stopC) {
+		return nil
+	}
+
+	e.logger.Infof("leader election: %s became the leader", e.config.Identity)
+	e.setLeading(true)
+	defer e.setLeading(false)
+	e.metrics.SetLeader(true)
+	defer e.metrics.SetLeader(false)
+
+	leadStopC := make(chan struct{})
+	leadC := make(chan error, 1)
+	leadDoneC := make(chan struct{})
+	var leadErr error
+	go func() {
+		defer close(leadDoneC)
+		leadErr = lead(leadStopC)
+		leadC <- leadErr
+	}()
+
+	err := e.renew(stopC, leadC)
+	close(leadStopC)
+	// Wait for lead to stop before releasing the leadership, a standby can take over once the
+	// LeadStopTimeout has passed anyway. A failed lead may not have stopped everything it started,
+	// so the lease is left to expire then.
+	select {
+	case <-leadDoneC:
+		if leadErr == nil {
+			e.release()
+		} else {
+			e.logger.Warningf("leader election: %s leaving the lease to expire as the leader failed: %v", e.config.Identity, leadErr)
+		}
+	case <-time.After(e.config.LeadStopTimeout()):
+		e.logger.Warningf("leader election: %s gave up waiting for the leader to stop after %s, leaving the lease to expire", e.config.Identity, e.config.LeadStopTimeout())
+	}
+	return err
+}
+
+// acquire tries to acquire the leadership until it succeeds or stopC is closed
+func (e *Elector) acquire(stopC <-chan struct{}) bool {
+	e.logger.Infof("leader election: %s waiting to acquire the leadership of %s/%s", e.config.Identity, e.config.Namespace, e.config.Name)
+	for {
+		if e.tryAcquireOrRenew() {
+			return true
+		}
+
+		select {
+		case <-stopC:
+			return false
+		case <-time.After(e.config.RetryPeriod):
+		}
+	}
+}
+
+// renew keeps renewing the leadership until it can't be renewed before the deadline, lead returns or stopC is closed
+func (e *Elector) renew(stopC <-chan struct{}, leadC <-chan error) error {
+	renewed := e.now()
+	for {
+		select {
+		case <-stopC:
+			return nil
+		case err := <-leadC:
+			return err
+		case <-time.After(e.config.RetryPeriod):
+		}
+
+		if e.tryAcquireOrRenew() {
+			renewed = e.now()
+		} else if e.now().Sub(renewed) > e.config.RenewDeadline {
+			e.logger.Warningf("leader election: %s lost the leadership of %s/%s", e.config.Identity, e.config.Namespace, e.config.Name)
+			return fmt.Errorf("leadership lost")
+		}
+	}
+}
+
+// tryAcquireOrRenew takes the lock if it's free, expired or already held, tells if this candidate holds it
+func (e *Elector) tryAcquireOrRenew() bool {
+	now := metav1.NewTime(e.now())
+	desired := record{
+		HolderIdentity:       e.config.Identity,
+		LeaseDurationSeconds: int(e.config.LeaseDuration / time.Second),
+		AcquireTime:          now,
+		RenewTime:            now,
+	}
+
+	cm, err := e.client.CoreV1().ConfigMaps(e.config.Namespace).Get(e.config.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   e.config.Namespace,
+				Name:        e.config.Name,
+				Annotations: map[string]string{},
+			},
+		}
+		if err := setRecord(cm, desired); err != nil {
+			e.logger.Errorf("leader election: could not encode leader record: %v", err)
+			return false
+		}
+		if _, err := e.client.CoreV1().ConfigMaps(e.config.Namespace).Create(cm); err != nil {
+			e.logger.Errorf("leader election: could not create lock %s/%s: %v", e.config.Namespace, e.config.Name, err)
+			return false
+		}
+		e.observe(desired)
+		e.setRoleKnown()
+		return true
+	} else if err != nil {
+		e.logger.Errorf("leader election: could not read lock %s/%s: %v", e.config.Namespace, e.config.Name, err)
+		return false
+	}
+
+	current, err := getRecord(cm)
+	if err != nil {
+		e.logger.Warningf("leader election: ignoring invalid leader record of %s/%s: %v", e.config.Namespace, e.config.Name, err)
+	}
+	if current != e.observedRecord {
+		if current.HolderIdentity != e.observedRecord.HolderIdentity && current.HolderIdentity != "" {
+			e.logger.Infof("leader election: %s is the leader", current.HolderIdentity)
+		}
+		e.observe(current)
+	}
+
+	held := current.HolderIdentity == e.config.Identity
+	expired := e.observedTime.Add(time.Duration(current.LeaseDurationSeconds) * time.Second).Before(e.now())
+	if !held && current.HolderIdentity != "" && !expired {
+		e.setRoleKnown()
+		return false
+	}
+
+	if held {
+		desired.AcquireTime = current.AcquireTime
+		desired.LeaderTransitions = current.LeaderTransitions
+	} else {
+		desired.LeaderTransitions = current.LeaderTransitions + 1
+	}
+
+	cm = cm.DeepCopy()
+	if cm.ObjectMeta.Annotations == nil {
+		cm.ObjectMeta.Annotations = map[string]string{}
+	}
+	if err := setRecord(cm, desired); err != nil {
+		e.logger.Errorf("leader election: could not encode leader record: %v", err)
+		return false
+	}
+	if _, err := e.client.CoreV1().ConfigMaps(e.config.Namespace).Update(cm); err != nil {
+		e.logger.Errorf("leader election: could not update lock %s/%s: %v", e.config.Namespace, e.config.Name, err)
+		return false
+	}
+
+	if !held {
+		e.metrics.IncLeaderTransitions()
+	}
+	e.observe(desired)
+	e.setRoleKnown()
+	return true
+}
+
+// release gives the leadership up so a standby doesn't need to wait for the lease to expire
+func (e *Elector) release() {
+	cm, err := e.client.CoreV1().ConfigMaps(e.config.Namespace).Get(e.config.Name, metav1.GetOptions{})
+	if err != nil {
+		e.logger.Errorf("leader election: could not read lock %s/%s: %v", e.config.Namespace, e.config.Name, err)
+		return
+	}
+
+	current, err := getRecord(cm)
+	if err != nil || current.HolderIdentity != e.config.Identity {
+		return
+	}
+
+	current.HolderIdentity = ""
+	current.LeaseDurationSeconds = 1
+	cm = cm.DeepCopy()
+	if err := setRecord(cm, current); err != nil {
+		e.logger.Errorf("leader election: could not encode leader record: %v", err)
+		return
+	}
+	if _, err := e.client.CoreV1().ConfigMaps(e.config.Namespace).Update(cm); err != nil {
+		e.logger.Errorf("leader election: could not release lock %s/%s: %v", e.config.Namespace, e.config.Name, err)
+		return
+	}
+	e.logger.Infof("leader election: %s released the leadership", e.config.Identity)
+}
+
+// RoleKnown tells if this candidate already knows whether it's the leader or a standby
+func (e *Elector) RoleKnown() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.roleKnown
+}
+
+// IsLeader tells if this candidate is currently the leader
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.leading
+}
+
+func (e *Elector) setRoleKnown() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.roleKnown = true
+}
+
+func (e *Elector) setLeading(leading bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.leading = leading
+}
+
+func (e *Elector) observe(r record) {
+	e.observedRecord = r
+	e.observedTime = e.now()
+}
+
+func getRecord(cm *corev1.ConfigMap) (record, error) {
+	var r record
+	value, ok := cm.ObjectMeta.Annotations[leaderAnnotationKey]
+	if !ok {
+		return r, nil
+	}
+	err := json.Unmarshal([]byte(value), &r)
+	return r, err
+}
+
+func setRecord(cm *corev1.ConfigMap, r record) error {
+	value, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	cm.ObjectMeta.Annotations[leaderAnnotationKey] = string(value)
+	return nil
+}
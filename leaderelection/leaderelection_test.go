@@ -0,0 +1,111 @@
+package leaderelection_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jeffersongirao/source-ranges-controller/leaderelection"
+	"github.com/jeffersongirao/source-ranges-controller/log"
+	"github.com/jeffersongirao/source-ranges-controller/metrics"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newElector(k8sCli kubernetes.Interface, identity string) *leaderelection.Elector {
+	cfg := leaderelection.Config{
+		Namespace:     metav1.NamespaceDefault,
+		Name:          "source-ranges-controller",
+		Identity:      identity,
+		LeaseDuration: time.Second,
+		RenewDeadline: 500 * time.Millisecond,
+		RetryPeriod:   10 * time.Millisecond,
+	}
+	return leaderelection.New(cfg, k8sCli, metrics.Dummy, log.Dummy)
+}
+
+// runElector runs the elector in the background, returning a channel receiving its identity
+// when it starts leading and the channel stopping it
+func runElector(e *leaderelection.Elector, identity string, ledC chan<- string) chan struct{} {
+	stopC := make(chan struct{})
+	go e.Run(stopC, func(leadStopC <-chan struct{}) error {
+		ledC <- identity
+		<-leadStopC
+		return nil
+	})
+	return stopC
+}
+
+func TestElectorHandsLeadershipOver(t *testing.T) {
+	k8sCli := fake.NewSimpleClientset()
+	ledC := make(chan string, 2)
+
+	stopA := runElector(newElector(k8sCli, "a"), "a", ledC)
+	select {
+	case identity := <-ledC:
+		assert.Equal(t, "a", identity)
+	case <-time.After(time.Second):
+		t.Fatal("Expected the first candidate to become the leader")
+	}
+
+	stopB := runElector(newElector(k8sCli, "b"), "b", ledC)
+	defer close(stopB)
+	select {
+	case identity := <-ledC:
+		t.Fatalf("Expected %s not to become the leader while the leadership is held", identity)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(stopA)
+	select {
+	case identity := <-ledC:
+		assert.Equal(t, "b", identity)
+	case <-time.After(time.Second):
+		t.Fatal("Expected the standby to take the released leadership over")
+	}
+
+	cm, err := k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Get("source-ranges-controller", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Contains(t, cm.ObjectMeta.Annotations["control-plane.alpha.kubernetes.io/leader"], `"holderIdentity":"b"`)
+	assert.Contains(t, cm.ObjectMeta.Annotations["control-plane.alpha.kubernetes.io/leader"], `"leaderTransitions":1`)
+}
+
+func TestElectorLeavesLeaseToExpireWhenLeadFails(t *testing.T) {
+	k8sCli := fake.NewSimpleClientset()
+	ledC := make(chan string, 2)
+
+	err := newElector(k8sCli, "a").Run(make(chan struct{}), func(_ <-chan struct{}) error {
+		return errors.New("could not drain the controller")
+	})
+	assert.EqualError(t, err, "could not drain the controller")
+
+	stopB := runElector(newElector(k8sCli, "b"), "b", ledC)
+	defer close(stopB)
+	select {
+	case identity := <-ledC:
+		t.Fatalf("Expected %s not to become the leader before the lease expired", identity)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	select {
+	case identity := <-ledC:
+		assert.Equal(t, "b", identity)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the standby to take over once the lease expired")
+	}
+}
+
+func TestConfigLeadStopTimeout(t *testing.T) {
+	cfg := leaderelection.Config{
+		LeaseDuration: 15 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   2 * time.Second,
+	}
+	assert.Equal(t, 3*time.Second, cfg.LeadStopTimeout())
+	assert.NoError(t, cfg.Validate())
+
+	cfg.RenewDeadline = 13 * time.Second
+	assert.Error(t, cfg.Validate())
+}
@@ -7,3 +7,6 @@ import (
 type Logger interface {
 	log.Logger
 }
+
+// Dummy logger doesn't log anything
+var Dummy Logger = log.Dummy
@@ -0,0 +1,19 @@
+package metrics
+
+import "time"
+
+// Dummy is a dummy metrics recorder.
+var Dummy = &dummy{}
+
+type dummy struct{}
+
+func (d *dummy) ObserveEnforcedService(_ string, _ string, _ int, _ time.Time) {}
+func (d *dummy) DeleteManagedService(_ string, _ string)                       {}
+func (d *dummy) IncDriftCorrections(_ string)                                  {}
+func (d *dummy) AddValidationRejections(_ string, _ string, _ int)             {}
+func (d *dummy) IncEnforcementErrors(_ string, _ string)                       {}
+func (d *dummy) IncRetriesExhausted(_ string, _ string, _ string)              {}
+func (d *dummy) IncEmptySourceRangesGuard(_ string, _ string)                  {}
+func (d *dummy) SetDryRunSourceRanges(_ string, _ string, _ int, _ int)        {}
+func (d *dummy) SetLeader(_ bool)                                              {}
+func (d *dummy) IncLeaderTransitions()                                         {}
@@ -0,0 +1,34 @@
+package metrics
+
+import "time"
+
+// Recorder knows how to record the source ranges enforcement metrics.
+type Recorder interface {
+	// ObserveEnforcedService records a successful enforcement of a managed Service at the given
+	// time along with the number of source ranges it got.
+	ObserveEnforcedService(namespace string, service string, sourceRanges int, t time.Time)
+	// DeleteManagedService forgets a Service that is no longer managed or was deleted.
+	DeleteManagedService(namespace string, service string)
+	// IncDriftCorrections increments in one the times the source ranges of a managed Service in
+	// the namespace didn't match its sources and were corrected.
+	IncDriftCorrections(namespace string)
+	// AddValidationRejections adds the invalid source ranges of the given kind of source that
+	// were left out of a Service in the namespace.
+	AddValidationRejections(namespace string, source string, rejected int)
+	// IncEnforcementErrors increments in one the errors enforcing source ranges to a Service in the
+	// namespace, the reason tells transient errors apart from permanent and policy ones.
+	IncEnforcementErrors(namespace string, reason string)
+	// IncRetriesExhausted increments in one the times a Service was left unenforced until the next
+	// resync because the change of the kind of resource triggering it failed every retry.
+	IncRetriesExhausted(kind string, namespace string, service string)
+	// IncEmptySourceRangesGuard increments in one the times the empty source ranges guard
+	// prevented a Service in the namespace from being opened to the world.
+	IncEmptySourceRangesGuard(namespace string, policy string)
+	// SetDryRunSourceRanges sets the source ranges a dry run would add to and remove from a
+	// Service, no changes clear them.
+	SetDryRunSourceRanges(namespace string, service string, added int, removed int)
+	// SetLeader sets if this replica is the leader.
+	SetLeader(leader bool)
+	// IncLeaderTransitions increments in one the times this replica took over the leadership.
+	IncLeaderTransitions()
+}
@@ -0,0 +1,220 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	promControllerSubsystem     = "controller"
+	promEnforcerSubsystem       = "enforcer"
+	promLeaderElectionSubsystem = "leader_election"
+)
+
+// Prometheus implements the metrics recording in a prometheus registry.
+type Prometheus struct {
+	managedServices        *prometheus.GaugeVec
+	serviceSourceRanges    *prometheus.GaugeVec
+	lastEnforcement        *prometheus.GaugeVec
+	driftCorrections       *prometheus.CounterVec
+	validationRejections   *prometheus.CounterVec
+	enforcementErrors      *prometheus.CounterVec
+	retriesExhausted       *prometheus.CounterVec
+	emptySourceRangesGuard *prometheus.CounterVec
+	dryRunSourceRanges     *prometheus.GaugeVec
+	leader                 prometheus.Gauge
+	leaderTransitions      prometheus.Counter
+
+	// managed holds the managed Services of each namespace, backing the managed Services gauge
+	mu      sync.Mutex
+	managed map[string]map[string]bool
+
+	reg prometheus.Registerer
+}
+
+// NewPrometheus returns a new Prometheus metrics backend with metrics prefixed by the namespace.
+func NewPrometheus(namespace string, registry prometheus.Registerer) *Prometheus {
+	p := &Prometheus{
+		managedServices: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: promEnforcerSubsystem,
+			Name:      "managed_services",
+			Help:      "Number of Services whose source ranges are managed by the controller.",
+		}, []string{"namespace"}),
+
+		serviceSourceRanges: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: promEnforcerSubsystem,
+			Name:      "service_source_ranges",
+			Help:      "Number of source ranges enforced to a Service.",
+		}, []string{"namespace", "service"}),
+
+		lastEnforcement: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: promEnforcerSubsystem,
+			Name:      "last_successful_enforcement_timestamp_seconds",
+			Help:      "Unix time of the last successful enforcement of a Service source ranges.",
+		}, []string{"namespace", "service"}),
+
+		driftCorrections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: promEnforcerSubsystem,
+			Name:      "drift_corrections_total",
+			Help:      "Total number of times the source ranges of a managed Service didn't match its sources and were corrected.",
+		}, []string{"namespace"}),
+
+		validationRejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: promEnforcerSubsystem,
+			Name:      "validation_rejections_total",
+			Help:      "Total number of invalid source ranges left out of Services.",
+		}, []string{"namespace", "source"}),
+
+		enforcementErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: promEnforcerSubsystem,
+			Name:      "errors_total",
+			Help:      "Total number of errors enforcing source ranges to Services by reason: transient, permanent or policy.",
+		}, []string{"namespace", "reason"}),
+
+		retriesExhausted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: promControllerSubsystem,
+			Name:      "retries_exhausted_total",
+			Help:      "Total number of times a Service was left unenforced until the next resync because the change triggering it failed every retry.",
+		}, []string{"kind", "namespace", "service"}),
+
+		emptySourceRangesGuard: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: promEnforcerSubsystem,
+			Name:      "empty_source_ranges_guard_total",
+			Help:      "Total number of times the empty source ranges guard kept a Service from being opened to the world.",
+		}, []string{"namespace", "policy"}),
+
+		dryRunSourceRanges: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: promEnforcerSubsystem,
+			Name:      "dry_run_pending_source_ranges",
+			Help:      "Number of source ranges a dry run would add to or remove from a Service.",
+		}, []string{"namespace", "service", "change"}),
+
+		leader: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: promLeaderElectionSubsystem,
+			Name:      "is_leader",
+			Help:      "Whether this replica is the leader, 1 when it is and 0 otherwise.",
+		}),
+
+		leaderTransitions: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: promLeaderElectionSubsystem,
+			Name:      "transitions_total",
+			Help:      "Total number of times this replica took over the leadership.",
+		}),
+
+		managed: map[string]map[string]bool{},
+		reg:     registry,
+	}
+
+	p.registerMetrics()
+	return p
+}
+
+func (p *Prometheus) registerMetrics() {
+	p.reg.MustRegister(
+		p.managedServices,
+		p.serviceSourceRanges,
+		p.lastEnforcement,
+		p.driftCorrections,
+		p.validationRejections,
+		p.enforcementErrors,
+		p.retriesExhausted,
+		p.emptySourceRangesGuard,
+		p.dryRunSourceRanges,
+		p.leader,
+		p.leaderTransitions,
+	)
+}
+
+// ObserveEnforcedService satisfies metrics.Recorder interface.
+func (p *Prometheus) ObserveEnforcedService(namespace string, service string, sourceRanges int, t time.Time) {
+	p.serviceSourceRanges.WithLabelValues(namespace, service).Set(float64(sourceRanges))
+	p.lastEnforcement.WithLabelValues(namespace, service).Set(float64(t.UnixNano()) / float64(time.Second))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.managed[namespace] == nil {
+		p.managed[namespace] = map[string]bool{}
+	}
+	p.managed[namespace][service] = true
+	p.managedServices.WithLabelValues(namespace).Set(float64(len(p.managed[namespace])))
+}
+
+// DeleteManagedService satisfies metrics.Recorder interface.
+func (p *Prometheus) DeleteManagedService(namespace string, service string) {
+	p.serviceSourceRanges.DeleteLabelValues(namespace, service)
+	p.lastEnforcement.DeleteLabelValues(namespace, service)
+	p.SetDryRunSourceRanges(namespace, service, 0, 0)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.managed[namespace][service] {
+		return
+	}
+	delete(p.managed[namespace], service)
+	p.managedServices.WithLabelValues(namespace).Set(float64(len(p.managed[namespace])))
+}
+
+// IncDriftCorrections satisfies metrics.Recorder interface.
+func (p *Prometheus) IncDriftCorrections(namespace string) {
+	p.driftCorrections.WithLabelValues(namespace).Inc()
+}
+
+// AddValidationRejections satisfies metrics.Recorder interface.
+func (p *Prometheus) AddValidationRejections(namespace string, source string, rejected int) {
+	p.validationRejections.WithLabelValues(namespace, source).Add(float64(rejected))
+}
+
+// IncEnforcementErrors satisfies metrics.Recorder interface.
+func (p *Prometheus) IncEnforcementErrors(namespace string, reason string) {
+	p.enforcementErrors.WithLabelValues(namespace, reason).Inc()
+}
+
+// IncRetriesExhausted satisfies metrics.Recorder interface.
+func (p *Prometheus) IncRetriesExhausted(kind string, namespace string, service string) {
+	p.retriesExhausted.WithLabelValues(kind, namespace, service).Inc()
+}
+
+// IncEmptySourceRangesGuard satisfies metrics.Recorder interface.
+func (p *Prometheus) IncEmptySourceRangesGuard(namespace string, policy string) {
+	p.emptySourceRangesGuard.WithLabelValues(namespace, policy).Inc()
+}
+
+// SetDryRunSourceRanges satisfies metrics.Recorder interface.
+func (p *Prometheus) SetDryRunSourceRanges(namespace string, service string, added int, removed int) {
+	if added == 0 && removed == 0 {
+		p.dryRunSourceRanges.DeleteLabelValues(namespace, service, "added")
+		p.dryRunSourceRanges.DeleteLabelValues(namespace, service, "removed")
+		return
+	}
+	p.dryRunSourceRanges.WithLabelValues(namespace, service, "added").Set(float64(added))
+	p.dryRunSourceRanges.WithLabelValues(namespace, service, "removed").Set(float64(removed))
+}
+
+// SetLeader satisfies metrics.Recorder interface.
+func (p *Prometheus) SetLeader(leader bool) {
+	if leader {
+		p.leader.Set(1)
+	} else {
+		p.leader.Set(0)
+	}
+}
+
+// IncLeaderTransitions satisfies metrics.Recorder interface.
+func (p *Prometheus) IncLeaderTransitions() {
+	p.leaderTransitions.Inc()
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeffersongirao/source-ranges-controller/log"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// How long a reviewed token is trusted before asking the API again
+	tokenReviewCacheTTL = time.Minute
+)
+
+// TokenReviewAuth only lets through the requests bearing a token the Kubernetes
+// API authenticates through a TokenReview, e.g. the service account token of Prometheus
+type TokenReviewAuth struct {
+	client kubernetes.Interface
+	logger log.Logger
+	now    func() time.Time
+
+	mu sync.Mutex
+	// reviews holds when the authentication of the hashed tokens expires
+	reviews map[[sha256.Size]byte]time.Time
+}
+
+// NewTokenReviewAuth returns a new TokenReviewAuth
+func NewTokenReviewAuth(k8sCli kubernetes.Interface, logger log.Logger) *TokenReviewAuth {
+	return &TokenReviewAuth{
+		client:  k8sCli,
+		logger:  logger,
+		now:     time.Now,
+		reviews: map[[sha256.Size]byte]time.Time{},
+	}
+}
+
+// Wrap returns a handler authenticating the requests before passing them to next
+func (a *TokenReviewAuth) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !a.authenticated(strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *TokenReviewAuth) authenticated(token string) bool {
+	if token == "" {
+		return false
+	}
+
+	key := sha256.Sum256([]byte(token))
+	a.mu.Lock()
+	expires, ok := a.reviews[key]
+	a.mu.Unlock()
+	if ok && a.now().Before(expires) {
+		return true
+	}
+
+	review, err := a.client.AuthenticationV1().TokenReviews().Create(&authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	})
+	if err != nil {
+		a.logger.Errorf("could not review token: %v", err)
+		return false
+	}
+	if !review.Status.Authenticated {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for k, expires := range a.reviews {
+		if !a.now().Before(expires) {
+			delete(a.reviews, k)
+		}
+	}
+	a.reviews[key] = a.now().Add(tokenReviewCacheTTL)
+	return true
+}
@@ -0,0 +1,58 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jeffersongirao/source-ranges-controller/log"
+	"github.com/jeffersongirao/source-ranges-controller/server"
+	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	kubetesting "k8s.io/client-go/testing"
+)
+
+func TestTokenReviewAuth(t *testing.T) {
+	k8sCli := fake.NewSimpleClientset()
+	reviews := 0
+	k8sCli.PrependReactor("create", "tokenreviews", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		reviews++
+		review := action.(kubetesting.CreateAction).GetObject().(*authenticationv1.TokenReview).DeepCopy()
+		review.Status.Authenticated = review.Spec.Token == "prometheus-token"
+		return true, review, nil
+	})
+
+	auth := server.NewTokenReviewAuth(k8sCli, log.Dummy)
+	h := auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("metrics"))
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		expectedCode  int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"not a bearer token", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{"invalid token", "Bearer other-token", http.StatusUnauthorized},
+		{"valid token", "Bearer prometheus-token", http.StatusOK},
+		{"cached valid token", "Bearer prometheus-token", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/metrics", nil)
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+		})
+	}
+
+	assert.Equal(t, 2, reviews, "valid tokens should only be reviewed once")
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/jeffersongirao/source-ranges-controller/log"
+)
+
+// Config is the configuration of the metrics and health checks server
+type Config struct {
+	// Addr is the address to listen on, e.g. :7777
+	Addr string
+	// TLSCertFile and TLSKeyFile enable TLS when set, they are reloaded when they change on disk
+	TLSCertFile string
+	TLSKeyFile  string
+}
+
+// Server serves the metrics and health checks of the controller
+type Server struct {
+	config   Config
+	server   *http.Server
+	listener net.Listener
+	logger   log.Logger
+}
+
+// New returns a new Server serving handler, the address is bound right away so
+// a port that can't be bound fails the startup instead of going unnoticed
+func New(config Config, handler http.Handler, logger log.Logger) (*Server, error) {
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		return nil, fmt.Errorf("both the TLS certificate and key files are required to enable TLS")
+	}
+
+	listener, err := net.Listen("tcp", config.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on %s: %v", config.Addr, err)
+	}
+
+	if config.TLSCertFile != "" {
+		certs, err := newCertReloader(config.TLSCertFile, config.TLSKeyFile, logger)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+		listener = tls.NewListener(listener, &tls.Config{
+			GetCertificate: certs.GetCertificate,
+			MinVersion:     tls.VersionTLS12,
+		})
+	}
+
+	return &Server{
+		config:   config,
+		server:   &http.Server{Handler: handler},
+		listener: listener,
+		logger:   logger,
+	}, nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Run serves the requests in the background
+func (s *Server) Run() {
+	go func() {
+		s.logger.Infof("serving metrics and health checks at %s (tls: %t)", s.Addr(), s.config.TLSCertFile != "")
+		if err := s.server.Serve(s.listener); err != nil && err != http.ErrServerClosed {
+			s.logger.Errorf("metrics and health checks server stopped: %v", err)
+		}
+	}()
+}
+
+// Shutdown stops serving, waiting for the requests being served until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
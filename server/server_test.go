@@ -0,0 +1,105 @@
+package server_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jeffersongirao/source-ranges-controller/log"
+	"github.com/jeffersongirao/source-ranges-controller/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFailsWhenAddressCantBeBound(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer listener.Close()
+
+	_, err = server.New(server.Config{Addr: listener.Addr().String()}, http.NotFoundHandler(), log.Dummy)
+	assert.NotNil(t, err)
+}
+
+func TestNewRequiresBothTLSFiles(t *testing.T) {
+	_, err := server.New(server.Config{Addr: "127.0.0.1:0", TLSCertFile: "tls.crt"}, http.NotFoundHandler(), log.Dummy)
+	assert.EqualError(t, err, "both the TLS certificate and key files are required to enable TLS")
+}
+
+func TestServerReloadsTLSCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "server")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeCertificate(t, certFile, keyFile, "first", time.Now().Add(-time.Minute))
+
+	s, err := server.New(server.Config{Addr: "127.0.0.1:0", TLSCertFile: certFile, TLSKeyFile: keyFile}, http.NotFoundHandler(), log.Dummy)
+	if !assert.Nil(t, err) {
+		return
+	}
+	s.Run()
+
+	assert.Equal(t, "first", servedCommonName(t, s.Addr()))
+
+	writeCertificate(t, certFile, keyFile, "second", time.Now())
+	assert.Equal(t, "second", servedCommonName(t, s.Addr()))
+}
+
+func servedCommonName(t *testing.T, addr string) string {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	if !assert.Nil(t, err) {
+		return ""
+	}
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+// writeCertificate writes a self-signed certificate for commonName, with the given modification time
+func writeCertificate(t *testing.T, certFile, keyFile, commonName string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	for file, data := range map[string][]byte{certFile: certPEM, keyFile: keyPEM} {
+		if err := ioutil.WriteFile(file, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jeffersongirao/source-ranges-controller/log"
+)
+
+// certReloader serves the TLS certificate from disk, reloading it whenever the
+// certificate or key files change so rotated certificates are picked up without a restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logger   log.Logger
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string, logger log.Logger) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, reloading it first if its files changed
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTime, err := r.lastModified()
+	if err != nil {
+		r.logger.Warningf("could not check TLS certificate %s, serving the loaded one: %v", r.certFile, err)
+		return r.cert, nil
+	}
+	if modTime.Equal(r.modTime) {
+		return r.cert, nil
+	}
+
+	if err := r.load(modTime); err != nil {
+		r.logger.Warningf("could not reload TLS certificate %s, serving the loaded one: %v", r.certFile, err)
+		return r.cert, nil
+	}
+	r.logger.Infof("reloaded TLS certificate %s", r.certFile)
+	return r.cert, nil
+}
+
+func (r *certReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTime, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+	return r.load(modTime)
+}
+
+func (r *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("could not load TLS certificate: %v", err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// lastModified returns the latest modification time of the certificate and key files
+func (r *certReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
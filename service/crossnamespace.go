@@ -0,0 +1,65 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// The annotation on a ConfigMap listing the namespaces allowed to reference it from other namespaces,
+	// * allows every namespace
+	allowedNamespacesAnnotationKey = "source-ranges.alpha.girao.net/allowed-namespaces"
+	// The annotation on a ConfigMap holding the label selector of the namespaces allowed to reference it from other namespaces
+	allowedNamespaceSelectorAnnotationKey = "source-ranges.alpha.girao.net/allowed-namespace-selector"
+)
+
+// splitReference returns the namespace and name of a name or namespace/name reference,
+// references without namespace are resolved in the given namespace
+func splitReference(namespace, ref string) (string, string, error) {
+	parts := strings.Split(ref, "/")
+	switch {
+	case len(parts) == 1:
+		return namespace, parts[0], nil
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return parts[0], parts[1], nil
+	}
+	return "", "", fmt.Errorf("%q is not a name or namespace/name reference", ref)
+}
+
+// checkNamespaceAllowed returns an error unless Services in namespace are allowed to reference the ConfigMap.
+// ConfigMaps can always be referenced from their own namespace, other namespaces must be
+// allowed by name or by label selector in the ConfigMap annotations.
+func (c *ConfigMapSourceRangeEnforcer) checkNamespaceAllowed(cm *corev1.ConfigMap, namespace string) error {
+	if cm.ObjectMeta.Namespace == namespace {
+		return nil
+	}
+
+	for _, allowed := range splitAnnotation(cm.ObjectMeta.Annotations[allowedNamespacesAnnotationKey]) {
+		if allowed == "*" || allowed == namespace {
+			return nil
+		}
+	}
+
+	if expr := cm.ObjectMeta.Annotations[allowedNamespaceSelectorAnnotationKey]; expr != "" {
+		selector, err := labels.Parse(expr)
+		if err != nil {
+			err = fmt.Errorf("invalid namespace selector %q in ConfigMap %s/%s: %v", expr, cm.ObjectMeta.Namespace, cm.ObjectMeta.Name, err)
+			return &classifiedError{reason: ErrorReasonPermanent, err: err}
+		}
+
+		ns, err := c.client.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if selector.Matches(labels.Set(ns.ObjectMeta.Labels)) {
+			return nil
+		}
+	}
+
+	err := fmt.Errorf("namespace %s is not allowed to reference ConfigMap %s/%s", namespace, cm.ObjectMeta.Namespace, cm.ObjectMeta.Name)
+	return &classifiedError{reason: ErrorReasonPolicy, err: err}
+}
@@ -0,0 +1,85 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/jeffersongirao/source-ranges-controller/service"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// enforceCrossNamespace enforces the network-policies/offices ConfigMap with the given annotations to a Service
+// of the team-a namespace with the given labels, returning the resulting Service and the events
+func enforceCrossNamespace(cmAnnotations map[string]string, nsLabels map[string]string) (*corev1.Service, []string, error) {
+	return enforceFixture{
+		objects: []runtime.Object{
+			&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "team-a",
+					Labels: nsLabels,
+				},
+			},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:   "network-policies",
+					Name:        "offices",
+					Annotations: cmAnnotations,
+				},
+				Data: map[string]string{
+					"lisbon": "123.123.123.123/32",
+				},
+			},
+		},
+		namespace: "team-a",
+		annotations: map[string]string{
+			"source-ranges.alpha.girao.net/config-map": "network-policies/offices",
+		},
+	}.enforce()
+}
+
+func TestEnforceSourceRangesToServiceWithConfigMapAllowingNamespace(t *testing.T) {
+	svc, _, err := enforceCrossNamespace(map[string]string{
+		"source-ranges.alpha.girao.net/allowed-namespaces": "team-b, team-a",
+	}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"123.123.123.123/32"}, svc.Spec.LoadBalancerSourceRanges)
+}
+
+func TestEnforceSourceRangesToServiceWithConfigMapAllowingNamespaceSelector(t *testing.T) {
+	svc, _, err := enforceCrossNamespace(map[string]string{
+		"source-ranges.alpha.girao.net/allowed-namespace-selector": "network-policies=offices",
+	}, map[string]string{"network-policies": "offices"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"123.123.123.123/32"}, svc.Spec.LoadBalancerSourceRanges)
+}
+
+func TestEnforceSourceRangesToServiceWithConfigMapNotAllowingNamespace(t *testing.T) {
+	svc, events, err := enforceCrossNamespace(map[string]string{
+		"source-ranges.alpha.girao.net/allowed-namespaces":         "team-b",
+		"source-ranges.alpha.girao.net/allowed-namespace-selector": "network-policies=offices",
+	}, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, service.ErrorReasonPolicy, service.ReasonOf(err))
+	assert.Nil(t, svc.Spec.LoadBalancerSourceRanges)
+
+	if eventCount := len(events); eventCount != 1 {
+		t.Errorf("Expected 1 event when ConfigMap doesn't allow the Service namespace but got %d", eventCount)
+		return
+	}
+
+	assert.Equal(t, "Warning SourceRangesEnforcementFailed could not use ConfigMap network-policies/offices: namespace team-a is not allowed to reference ConfigMap network-policies/offices (reason: policy)", events[0])
+}
+
+func TestConfigMapReferencesAcrossNamespaces(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team-a",
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/config-map": "whitelist,network-policies/offices,a/b/c",
+			},
+		},
+	}
+	assert.Equal(t, []string{"team-a/whitelist", "network-policies/offices"}, service.ConfigMapReferences(svc))
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/jeffersongirao/source-ranges-controller/cidr"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// The annotation used for publishing the descriptions of the Service source ranges, as a JSON object
+	// from each described source range to its descriptions, so they can be read through the API
+	descriptionsAnnotationKey = "source-ranges.alpha.girao.net/source-range-descriptions"
+)
+
+// sourceDescriptions maps the canonical source ranges to their descriptions
+type sourceDescriptions map[string][]string
+
+func (d sourceDescriptions) add(value, description string) {
+	if description == "" {
+		return
+	}
+	for _, existing := range d[value] {
+		if existing == description {
+			return
+		}
+	}
+	d[value] = append(d[value], description)
+}
+
+// annotation returns the descriptions of the given source ranges as the value of the descriptions
+// annotation, empty when none of them is described
+func (d sourceDescriptions) annotation(sourceRanges []string) string {
+	described := map[string]string{}
+	for _, sourceRange := range cidr.NewSet(sourceRanges...).List() {
+		if descriptions := d[sourceRange]; len(descriptions) != 0 {
+			described[sourceRange] = strings.Join(descriptions, "; ")
+		}
+	}
+	if len(described) == 0 {
+		return ""
+	}
+
+	annotation, err := json.Marshal(described)
+	if err != nil {
+		return ""
+	}
+	return string(annotation)
+}
+
+// descriptionsChanged tells if the descriptions annotation of the Service differs from the given one
+func descriptionsChanged(svc *corev1.Service, annotation string) bool {
+	return svc.ObjectMeta.Annotations[descriptionsAnnotationKey] != annotation
+}
+
+// setDescriptions publishes the descriptions on the Service, an empty annotation removes them
+func setDescriptions(svc *corev1.Service, annotation string) {
+	if annotation == "" {
+		delete(svc.ObjectMeta.Annotations, descriptionsAnnotationKey)
+		return
+	}
+	svc.ObjectMeta.Annotations[descriptionsAnnotationKey] = annotation
+}
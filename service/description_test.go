@@ -0,0 +1,52 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnforceSourceRangesToServicePublishesDescriptions(t *testing.T) {
+	data := map[string]string{
+		"offices": "203.0.113.0/24  # Lisbon office\n198.51.100.0/24, 198.51.101.0/24  # Porto office",
+		"vendors": "203.0.113.0/24  # Shared with the vendors\n192.0.2.0/24",
+		"ranges.yaml": `
+- cidr: 10.0.0.0/8
+  description: VPN
+  owner: network-team
+`,
+	}
+	svc, _, err := enforceFixture{data: data, annotations: withConfigMap(nil)}.enforce()
+	assert.NoError(t, err)
+
+	assert.Equal(t,
+		`{"10.0.0.0/8":"VPN, owner network-team","198.51.100.0/24":"Porto office","198.51.101.0/24":"Porto office","203.0.113.0/24":"Lisbon office; Shared with the vendors"}`,
+		svc.ObjectMeta.Annotations["source-ranges.alpha.girao.net/source-range-descriptions"])
+}
+
+func TestEnforceSourceRangesToServiceUpdatesDescriptions(t *testing.T) {
+	data := map[string]string{
+		"offices": "203.0.113.0/24  # Lisbon office",
+	}
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/original-source-ranges":    "",
+		"source-ranges.alpha.girao.net/source-range-descriptions": `{"203.0.113.0/24":"Old office"}`,
+	}
+	svc, events, err := enforceFixture{data: data, annotations: withConfigMap(annotations), sourceRanges: []string{"203.0.113.0/24"}}.enforce()
+	assert.NoError(t, err)
+
+	assert.Equal(t, `{"203.0.113.0/24":"Lisbon office"}`, svc.ObjectMeta.Annotations["source-ranges.alpha.girao.net/source-range-descriptions"])
+	assert.Empty(t, events, "Expected no event when only the descriptions changed")
+}
+
+func TestEnforceSourceRangesToServiceWithoutDescriptions(t *testing.T) {
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/original-source-ranges":    "",
+		"source-ranges.alpha.girao.net/source-range-descriptions": `{"203.0.113.0/24":"Lisbon office"}`,
+	}
+	svc, _, err := enforceFixture{data: map[string]string{"offices": "203.0.113.0/24"}, annotations: withConfigMap(annotations), sourceRanges: []string{"203.0.113.0/24"}}.enforce()
+	assert.NoError(t, err)
+
+	_, ok := svc.ObjectMeta.Annotations["source-ranges.alpha.girao.net/source-range-descriptions"]
+	assert.False(t, ok)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jeffersongirao/source-ranges-controller/cidr"
+	"github.com/jeffersongirao/source-ranges-controller/log"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// sourceRange is a canonical source range along with where it comes from and its description
+type sourceRange struct {
+	value       string
+	origin      string
+	description string
+}
+
+// describeOrigin appends the description of a source range to where it comes from, if any
+func describeOrigin(origin, description string) string {
+	if description == "" {
+		return origin
+	}
+	return fmt.Sprintf("%s %q", origin, description)
+}
+
+// sourceOrigins maps the canonical source ranges to the sources they come from
+type sourceOrigins map[string][]string
+
+func (o sourceOrigins) add(value, origin string) {
+	for _, existing := range o[value] {
+		if existing == origin {
+			return
+		}
+	}
+	o[value] = append(o[value], origin)
+}
+
+// sourceRangesDiff is the change applying source ranges makes to a Service, confidential
+// diffs only describe how many source ranges changed and where the added ones come from
+type sourceRangesDiff struct {
+	added        []string
+	removed      []string
+	origins      sourceOrigins
+	confidential bool
+}
+
+func newSourceRangesDiff(current, desired []string, origins sourceOrigins) sourceRangesDiff {
+	currentSet := cidr.NewSet(current...)
+	desiredSet := cidr.NewSet(desired...)
+	return sourceRangesDiff{
+		added:   desiredSet.Difference(currentSet),
+		removed: currentSet.Difference(desiredSet),
+		origins: origins,
+	}
+}
+
+// String describes the added source ranges along with where they come from and the removed ones
+func (d sourceRangesDiff) String() string {
+	if d.confidential {
+		added := fmt.Sprintf("%d", len(d.added))
+		if origins := d.addedOrigins(); len(origins) != 0 {
+			added = fmt.Sprintf("%s (%s)", added, strings.Join(origins, ", "))
+		}
+		return fmt.Sprintf("added: %s; removed: %d", added, len(d.removed))
+	}
+
+	added := make([]string, 0, len(d.added))
+	for _, value := range d.added {
+		if origins := d.origins[value]; len(origins) != 0 {
+			value = fmt.Sprintf("%s (%s)", value, strings.Join(origins, ", "))
+		}
+		added = append(added, value)
+	}
+	return fmt.Sprintf("added: %s; removed: %s", joinOrNone(added, ", "), joinOrNone(d.removed, ", "))
+}
+
+// log writes the diff to the logger as logfmt fields, one line per added source range
+// so its origins can be followed
+func (d sourceRangesDiff) log(logger log.Logger, msg string, svc *corev1.Service) {
+	if d.confidential {
+		logger.Infof("%s service=%s/%s added=%d removed=%d origin=%q", msg, svc.ObjectMeta.Namespace, svc.ObjectMeta.Name, len(d.added), len(d.removed), strings.Join(d.addedOrigins(), ","))
+		return
+	}
+	logger.Infof("%s service=%s/%s added=%q removed=%q", msg, svc.ObjectMeta.Namespace, svc.ObjectMeta.Name, strings.Join(d.added, ","), strings.Join(d.removed, ","))
+	for _, value := range d.added {
+		logger.Infof("%s service=%s/%s source_range=%s origin=%q", msg, svc.ObjectMeta.Namespace, svc.ObjectMeta.Name, value, strings.Join(d.origins[value], ","))
+	}
+}
+
+// addedOrigins returns the de-duplicated origins of the added source ranges
+func (d sourceRangesDiff) addedOrigins() []string {
+	var origins []string
+	seen := map[string]bool{}
+	for _, value := range d.added {
+		for _, origin := range d.origins[value] {
+			if !seen[origin] {
+				seen[origin] = true
+				origins = append(origins, origin)
+			}
+		}
+	}
+	return origins
+}
+
+func joinOrNone(values []string, sep string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, sep)
+}
@@ -0,0 +1,79 @@
+package service_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jeffersongirao/source-ranges-controller/apis/sourceranges/v1alpha1"
+	"github.com/jeffersongirao/source-ranges-controller/service"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+type fakeLogger struct {
+	lines []string
+}
+
+func (f *fakeLogger) Infof(format string, args ...interface{}) {
+	f.lines = append(f.lines, fmt.Sprintf(format, args...))
+}
+func (f *fakeLogger) Warningf(format string, args ...interface{}) {}
+func (f *fakeLogger) Errorf(format string, args ...interface{})   {}
+
+func TestEnforceSourceRangesToServiceReportsDiff(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-config",
+		},
+		Data: map[string]string{
+			"office": "10.0.0.0/8",
+			"vpn":    "192.168.0.0/16",
+		},
+	}
+	k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Create(cm)
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Name:      "test-service",
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/config-map":        "test-config",
+				"source-ranges.alpha.girao.net/source-range-sets": "corp",
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			LoadBalancerSourceRanges: []string{"10.0.0.0/8", "123.123.123.123/32"},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	getter := &fakeSourceRangeSetGetter{
+		sets: map[string]*v1alpha1.SourceRangeSet{
+			"default/corp": {
+				Spec: v1alpha1.SourceRangeSetSpec{
+					SourceRanges: []v1alpha1.SourceRange{
+						{CIDR: "192.168.0.0/16", Description: "vpn"},
+					},
+				},
+			},
+		},
+	}
+	logger := &fakeLogger{}
+	recorder := record.NewFakeRecorder(1)
+	e := service.NewConfigMapSourceRangeEnforcerWithConfig(service.Config{SourceRangeSetGetter: getter, Logger: logger}, k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		"Normal SourceRangesEnforcementSuccessful Updated Service test-service LB source ranges, added: 192.168.0.0/16 (ConfigMap test-config key vpn, SourceRangeSet corp \"vpn\"); removed: 123.123.123.123/32",
+	}, collectEvents(recorder.Events))
+	assert.Equal(t, []string{
+		`updated source ranges service=default/test-service added="192.168.0.0/16" removed="123.123.123.123/32"`,
+		`updated source ranges service=default/test-service source_range=192.168.0.0/16 origin="ConfigMap test-config key vpn,SourceRangeSet corp \"vpn\""`,
+	}, logger.lines)
+}
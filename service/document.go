@@ -0,0 +1,94 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+)
+
+// The suffixes of the ConfigMap keys holding a YAML or JSON document with a list of source ranges
+// instead of flat values
+var documentKeySuffixes = []string{".yaml", ".yml", ".json"}
+
+// documentEntry is a source range of a YAML or JSON document
+type documentEntry struct {
+	CIDR        string   `json:"cidr"`
+	Description string   `json:"description,omitempty"`
+	Owner       string   `json:"owner,omitempty"`
+	Ticket      string   `json:"ticket,omitempty"`
+	Expires     string   `json:"expires,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// isDocumentKey tells if the ConfigMap key holds a YAML or JSON document
+func isDocumentKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, suffix := range documentKeySuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// documentEntries returns the source ranges of a YAML or JSON document along with their descriptions,
+// leaving out the expired ones. Documents not matching the schema are rejected as a whole, the errors
+// never echo the document values.
+func documentEntries(document string, now time.Time) ([]sourceRange, error) {
+	j, err := yaml.YAMLToJSON([]byte(document))
+	if err != nil {
+		return nil, fmt.Errorf("not a YAML or JSON document: %v", err)
+	}
+
+	var docEntries []documentEntry
+	decoder := json.NewDecoder(bytes.NewReader(j))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&docEntries); err != nil {
+		return nil, fmt.Errorf("not a list of source ranges with cidr, description, owner, ticket, expires and tags: %v", err)
+	}
+
+	entries := make([]sourceRange, 0, len(docEntries))
+	for i, entry := range docEntries {
+		if strings.TrimSpace(entry.CIDR) == "" {
+			return nil, fmt.Errorf("entry %d: cidr is required", i+1)
+		}
+		if entry.Expires != "" {
+			expires, err := parseExpires(entry.Expires)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: expires must be a date like 2006-01-02 or an RFC 3339 time", i+1)
+			}
+			if !now.Before(expires) {
+				continue
+			}
+		}
+		entries = append(entries, sourceRange{value: strings.TrimSpace(entry.CIDR), description: entry.describe()})
+	}
+	return entries, nil
+}
+
+// describe returns the description of the entry along with its owner and ticket, if any
+func (e documentEntry) describe() string {
+	var parts []string
+	if e.Description != "" {
+		parts = append(parts, e.Description)
+	}
+	if e.Owner != "" {
+		parts = append(parts, "owner "+e.Owner)
+	}
+	if e.Ticket != "" {
+		parts = append(parts, "ticket "+e.Ticket)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseExpires parses a date, which expires at the start of the day in UTC, or an RFC 3339 time
+func parseExpires(value string) (time.Time, error) {
+	if expires, err := time.Parse("2006-01-02", value); err == nil {
+		return expires, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
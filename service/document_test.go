@@ -0,0 +1,106 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/jeffersongirao/source-ranges-controller/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnforceSourceRangesToServiceWithDocument(t *testing.T) {
+	svc, events, err := enforceFixture{
+		data: map[string]string{
+			"ranges.yaml": `
+- cidr: 203.0.113.0/24
+  description: Lisbon office
+  owner: network-team
+  ticket: NET-42
+  tags: [office, eu]
+- cidr: 198.51.100.7
+  expires: 2999-01-01
+- cidr: 192.0.2.0/24
+  description: Old vendor
+  expires: 2000-01-01T00:00:00Z
+- cidr: not-a-cidr
+`,
+			"partners.json": `[{"cidr": "192.0.2.128/25", "description": "Acme"}]`,
+			"office":        "172.16.0.0/12",
+		},
+		annotations:  withConfigMap(nil),
+		sourceRanges: []string{"10.0.0.0/8"},
+	}.enforce()
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"172.16.0.0/12", "192.0.2.128/25", "198.51.100.7/32", "203.0.113.0/24"}, svc.Spec.LoadBalancerSourceRanges)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "Warning SourceRangesValidationFailed ignored invalid source ranges from ConfigMap test-config keys: ranges.yaml", events[0])
+		assert.Contains(t, events[1], `203.0.113.0/24 (ConfigMap test-config key ranges.yaml "Lisbon office, owner network-team, ticket NET-42")`)
+		assert.Contains(t, events[1], `192.0.2.128/25 (ConfigMap test-config key partners.json "Acme")`)
+		assert.Contains(t, events[1], "198.51.100.7/32 (ConfigMap test-config key ranges.yaml)")
+	}
+}
+
+func TestEnforceSourceRangesToServiceWithInvalidDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		message  string
+	}{
+		{
+			name:     "unknown field",
+			document: "- cidr: 203.0.113.0/24\n  owners: network-team\n",
+			message:  `key ranges.yaml: not a list of source ranges with cidr, description, owner, ticket, expires and tags: json: unknown field "owners"`,
+		},
+		{
+			name:     "missing cidr",
+			document: "- cidr: 203.0.113.0/24\n- description: Lisbon office\n",
+			message:  "key ranges.yaml: entry 2: cidr is required",
+		},
+		{
+			name:     "invalid expires",
+			document: "- cidr: 203.0.113.0/24\n  expires: next week\n",
+			message:  "key ranges.yaml: entry 1: expires must be a date like 2006-01-02 or an RFC 3339 time",
+		},
+		{
+			name:     "not a list",
+			document: "cidr: 203.0.113.0/24\n",
+		},
+		{
+			name:     "not YAML",
+			document: "- cidr: [203.0.113.0/24\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc, events, err := enforceFixture{
+				data:         map[string]string{"ranges.yaml": test.document},
+				annotations:  withConfigMap(nil),
+				sourceRanges: []string{"10.0.0.0/8"},
+			}.enforce()
+
+			assert.Error(t, err)
+			assert.Equal(t, service.ErrorReasonPermanent, service.ReasonOf(err))
+			if test.message != "" {
+				assert.EqualError(t, err, test.message)
+			}
+			assert.Equal(t, []string{"10.0.0.0/8"}, svc.Spec.LoadBalancerSourceRanges)
+			if assert.Len(t, events, 1) {
+				assert.Contains(t, events[0], "Warning SourceRangesEnforcementFailed invalid source ranges document in ConfigMap test-config key ranges.yaml: ")
+			}
+		})
+	}
+}
+
+func TestEnforceSourceRangesToServiceWithDisabledDocument(t *testing.T) {
+	svc, _, err := enforceFixture{
+		data: map[string]string{
+			"ranges.yaml.disabled": "not a document",
+			"office":               "172.16.0.0/12",
+		},
+		annotations: withConfigMap(nil),
+	}.enforce()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"172.16.0.0/12"}, svc.Spec.LoadBalancerSourceRanges)
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// The annotation used for observing a Service without mutating it
+	dryRunAnnotationKey = "source-ranges.alpha.girao.net/dry-run"
+)
+
+// isDryRun tells if the Service source ranges must be left untouched, either because the whole
+// controller or the Service annotation says so
+func (c *ConfigMapSourceRangeEnforcer) isDryRun(svc *corev1.Service) (bool, error) {
+	value, ok := svc.ObjectMeta.Annotations[dryRunAnnotationKey]
+	if c.dryRun || !ok {
+		return c.dryRun, nil
+	}
+
+	dryRun, err := strconv.ParseBool(value)
+	if err != nil {
+		message := fmt.Sprintf("invalid annotation %s: %q is not a boolean", dryRunAnnotationKey, value)
+		return false, c.fail(svc, ErrorReasonPermanent, err, message)
+	}
+	return dryRun, nil
+}
+
+// recordDryRun reports the changes that applying the source ranges would make to the Service, an empty diff
+// clears them. The changes are only logged and emitted as an event when they differ from the last ones reported,
+// so the same pending changes aren't reported again on every resync.
+func (c *ConfigMapSourceRangeEnforcer) recordDryRun(svc *corev1.Service, diff sourceRangesDiff) {
+	c.metrics.SetDryRunSourceRanges(svc.ObjectMeta.Namespace, svc.ObjectMeta.Name, len(diff.added), len(diff.removed))
+
+	message := ""
+	if len(diff.added) != 0 || len(diff.removed) != 0 {
+		message = fmt.Sprintf("dry run, Service %s LB source ranges would be updated, %s", svc.ObjectMeta.Name, diff)
+	}
+
+	key := svc.ObjectMeta.Namespace + "/" + svc.ObjectMeta.Name
+	c.mu.Lock()
+	last := c.dryRuns[key]
+	if message == "" {
+		delete(c.dryRuns, key)
+	} else {
+		c.dryRuns[key] = message
+	}
+	c.mu.Unlock()
+
+	if message == "" || message == last {
+		return
+	}
+	diff.log(c.logger, "dry run, would update source ranges", svc)
+	reason := "SourceRangesDryRun"
+	c.recorder.Event(svc, corev1.EventTypeNormal, reason, message)
+}
+
+// clearDryRun clears the changes a dry run reported for the Service
+func (c *ConfigMapSourceRangeEnforcer) clearDryRun(svc *corev1.Service) {
+	c.recordDryRun(svc, sourceRangesDiff{})
+}
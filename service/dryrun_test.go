@@ -0,0 +1,134 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/jeffersongirao/source-ranges-controller/service"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// dryRunData is the data of the ConfigMap the dry run tests enforce
+var dryRunData = map[string]string{
+	"test":  "10.0.0.0/8",
+	"test2": "192.168.0.0/16",
+}
+
+func TestEnforceSourceRangesToServiceInDryRun(t *testing.T) {
+	m := &fakeMetricsRecorder{}
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/config-map": "test-config",
+	}
+	svc, events, err := enforceFixture{config: service.Config{DryRun: true, MetricsRecorder: m}, data: dryRunData, annotations: annotations, sourceRanges: []string{"10.0.0.0/8", "123.123.123.123/32"}}.enforce()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"10.0.0.0/8", "123.123.123.123/32"}, svc.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, annotations, svc.ObjectMeta.Annotations)
+	assert.Equal(t, []string{
+		"Normal SourceRangesDryRun dry run, Service test-service LB source ranges would be updated, added: 192.168.0.0/16 (ConfigMap test-config key test2); removed: 123.123.123.123/32",
+	}, events)
+	assert.Equal(t, map[string]int{"default/test-service/added": 1, "default/test-service/removed": 1}, m.dryRunSourceRanges)
+}
+
+func TestEnforceSourceRangesToServiceInDryRunReportsChangesOnce(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Name:      "test-config",
+		},
+		Data: map[string]string{
+			"test": "10.0.0.0/8",
+		},
+	}
+	k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Create(cm)
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   metav1.NamespaceDefault,
+			Name:        "test-service",
+			Annotations: withConfigMap(nil),
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	m := &fakeMetricsRecorder{}
+	recorder := record.NewFakeRecorder(10)
+	e := service.NewConfigMapSourceRangeEnforcerWithConfig(service.Config{DryRun: true, MetricsRecorder: m}, k8sCli, recorder)
+
+	assert.Nil(t, e.EnforceSourceRangesToService(svc))
+	assert.Nil(t, e.EnforceSourceRangesToService(svc))
+	assert.Equal(t, []string{
+		"Normal SourceRangesDryRun dry run, Service test-service LB source ranges would be updated, added: 10.0.0.0/8 (ConfigMap test-config key test); removed: none",
+	}, collectEvents(recorder.Events))
+	assert.Equal(t, map[string]int{"default/test-service/added": 1, "default/test-service/removed": 0}, m.dryRunSourceRanges)
+
+	cm.Data["test2"] = "192.168.0.0/16"
+	k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Update(cm)
+	assert.Nil(t, e.EnforceSourceRangesToService(svc))
+	assert.Len(t, collectEvents(recorder.Events), 1)
+	assert.Equal(t, 2, m.dryRunSourceRanges["default/test-service/added"])
+
+	svc.Spec.LoadBalancerSourceRanges = []string{"10.0.0.0/8", "192.168.0.0/16"}
+	assert.Nil(t, e.EnforceSourceRangesToService(svc))
+	assert.Empty(t, collectEvents(recorder.Events))
+	assert.Empty(t, m.dryRunSourceRanges)
+}
+
+func TestEnforceSourceRangesToServiceInDryRunByAnnotation(t *testing.T) {
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/config-map": "test-config",
+		"source-ranges.alpha.girao.net/dry-run":    "true",
+	}
+	svc, events, err := enforceFixture{config: service.Config{}, data: dryRunData, annotations: annotations, sourceRanges: nil}.enforce()
+	assert.Nil(t, err)
+
+	assert.Nil(t, svc.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, []string{
+		"Normal SourceRangesDryRun dry run, Service test-service LB source ranges would be updated, added: 10.0.0.0/8 (ConfigMap test-config key test), 192.168.0.0/16 (ConfigMap test-config key test2); removed: none",
+	}, events)
+}
+
+func TestEnforceSourceRangesToServiceInDryRunWithNoChanges(t *testing.T) {
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/config-map": "test-config",
+	}
+	_, events, err := enforceFixture{config: service.Config{DryRun: true}, data: dryRunData, annotations: annotations, sourceRanges: []string{"10.0.0.0/8", "192.168.0.0/16"}}.enforce()
+	assert.Nil(t, err)
+
+	if eventCount := len(events); eventCount != 0 {
+		t.Errorf("Expected 0 event when a dry run has nothing to change but got %d", eventCount)
+	}
+}
+
+func TestEnforceSourceRangesToServiceInDryRunWhenReleasing(t *testing.T) {
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/original-source-ranges": "123.123.123.123/32",
+		"source-ranges.alpha.girao.net/dry-run":                "true",
+	}
+	svc, events, err := enforceFixture{config: service.Config{}, data: dryRunData, annotations: annotations, sourceRanges: []string{"10.0.0.0/8"}}.enforce()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"10.0.0.0/8"}, svc.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, annotations, svc.ObjectMeta.Annotations)
+	assert.Equal(t, []string{
+		"Normal SourceRangesDryRun dry run, Service test-service LB source ranges would be updated, added: 123.123.123.123/32; removed: 10.0.0.0/8",
+	}, events)
+}
+
+func TestEnforceSourceRangesToServiceWithInvalidDryRun(t *testing.T) {
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/config-map": "test-config",
+		"source-ranges.alpha.girao.net/dry-run":    "maybe",
+	}
+	svc, events, err := enforceFixture{config: service.Config{}, data: dryRunData, annotations: annotations, sourceRanges: nil}.enforce()
+	assert.NotNil(t, err)
+
+	assert.Nil(t, svc.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, []string{
+		"Warning SourceRangesEnforcementFailed invalid annotation source-ranges.alpha.girao.net/dry-run: \"maybe\" is not a boolean (reason: permanent)",
+	}, events)
+}
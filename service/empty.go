@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// The annotation used for overriding the empty source ranges policy of a Service
+	emptySourceRangesPolicyAnnotationKey = "source-ranges.alpha.girao.net/empty-source-ranges-policy"
+
+	// denyAllSourceRange is a source range no client can connect from, applied so an
+	// empty list of source ranges doesn't allow everyone
+	denyAllSourceRange = "127.0.0.1/32"
+)
+
+// EmptySourceRangesPolicy tells what to do when the referenced sources hold no source ranges,
+// as an empty loadBalancerSourceRanges allows everyone
+type EmptySourceRangesPolicy string
+
+const (
+	// EmptySourceRangesKeep keeps the last applied source ranges, or denies everyone if there are none
+	EmptySourceRangesKeep EmptySourceRangesPolicy = "keep"
+	// EmptySourceRangesDeny applies a source range no client can connect from
+	EmptySourceRangesDeny EmptySourceRangesPolicy = "deny"
+	// EmptySourceRangesAllow applies the empty list of source ranges, allowing everyone
+	EmptySourceRangesAllow EmptySourceRangesPolicy = "allow"
+)
+
+// ParseEmptySourceRangesPolicy returns the EmptySourceRangesPolicy with the given name
+func ParseEmptySourceRangesPolicy(value string) (EmptySourceRangesPolicy, error) {
+	switch policy := EmptySourceRangesPolicy(value); policy {
+	case EmptySourceRangesKeep, EmptySourceRangesDeny, EmptySourceRangesAllow:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid empty source ranges policy %q, must be one of %s, %s or %s", value, EmptySourceRangesKeep, EmptySourceRangesDeny, EmptySourceRangesAllow)
+}
+
+// guardEmptySourceRanges returns the source ranges to apply to a Service whose sources hold no source ranges
+func (c *ConfigMapSourceRangeEnforcer) guardEmptySourceRanges(svc *corev1.Service) ([]string, error) {
+	policy := c.emptyPolicy
+	if value, ok := svc.ObjectMeta.Annotations[emptySourceRangesPolicyAnnotationKey]; ok {
+		var err error
+		if policy, err = ParseEmptySourceRangesPolicy(value); err != nil {
+			message := fmt.Sprintf("invalid annotation %s: %v", emptySourceRangesPolicyAnnotationKey, err)
+			return nil, c.fail(svc, ErrorReasonPermanent, err, message)
+		}
+	}
+
+	if policy == EmptySourceRangesAllow {
+		return []string{}, nil
+	}
+
+	sourceRanges := svc.Spec.LoadBalancerSourceRanges
+	if policy == EmptySourceRangesDeny || len(sourceRanges) == 0 {
+		sourceRanges = []string{denyAllSourceRange}
+	}
+
+	c.metrics.IncEmptySourceRangesGuard(svc.ObjectMeta.Namespace, string(policy))
+	reason := "SourceRangesEmptyGuard"
+	applied := fmt.Sprintf("%v", sourceRanges)
+	if isConfidential(svc) {
+		applied = fmt.Sprintf("%d source ranges", len(sourceRanges))
+	}
+	message := fmt.Sprintf("referenced sources hold no source ranges, applying %s to Service %s instead of allowing everyone (policy %s)", applied, svc.ObjectMeta.Name, policy)
+	c.recorder.Event(svc, corev1.EventTypeWarning, reason, message)
+	return sourceRanges, nil
+}
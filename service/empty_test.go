@@ -0,0 +1,147 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jeffersongirao/source-ranges-controller/metrics"
+	"github.com/jeffersongirao/source-ranges-controller/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMetricsRecorder records the enforcement metrics, the rest of metrics.Recorder is left unimplemented
+type fakeMetricsRecorder struct {
+	metrics.Recorder
+
+	enforcedServices       map[string]int
+	driftCorrections       map[string]int
+	validationRejections   map[string]int
+	enforcementErrors      map[string]int
+	emptySourceRangesGuard map[string]int
+	dryRunSourceRanges     map[string]int
+}
+
+func (f *fakeMetricsRecorder) ObserveEnforcedService(namespace string, service string, sourceRanges int, _ time.Time) {
+	if f.enforcedServices == nil {
+		f.enforcedServices = map[string]int{}
+	}
+	f.enforcedServices[namespace+"/"+service] = sourceRanges
+}
+
+func (f *fakeMetricsRecorder) DeleteManagedService(namespace string, service string) {
+	delete(f.enforcedServices, namespace+"/"+service)
+}
+
+func (f *fakeMetricsRecorder) IncDriftCorrections(namespace string) {
+	if f.driftCorrections == nil {
+		f.driftCorrections = map[string]int{}
+	}
+	f.driftCorrections[namespace]++
+}
+
+func (f *fakeMetricsRecorder) AddValidationRejections(namespace string, source string, rejected int) {
+	if f.validationRejections == nil {
+		f.validationRejections = map[string]int{}
+	}
+	f.validationRejections[namespace+"/"+source] += rejected
+}
+
+func (f *fakeMetricsRecorder) IncEnforcementErrors(namespace string, reason string) {
+	if f.enforcementErrors == nil {
+		f.enforcementErrors = map[string]int{}
+	}
+	f.enforcementErrors[namespace+"/"+reason]++
+}
+
+func (f *fakeMetricsRecorder) IncEmptySourceRangesGuard(namespace string, policy string) {
+	if f.emptySourceRangesGuard == nil {
+		f.emptySourceRangesGuard = map[string]int{}
+	}
+	f.emptySourceRangesGuard[namespace+"/"+policy]++
+}
+
+func (f *fakeMetricsRecorder) SetDryRunSourceRanges(namespace string, service string, added int, removed int) {
+	if f.dryRunSourceRanges == nil {
+		f.dryRunSourceRanges = map[string]int{}
+	}
+	key := namespace + "/" + service
+	if added == 0 && removed == 0 {
+		delete(f.dryRunSourceRanges, key+"/added")
+		delete(f.dryRunSourceRanges, key+"/removed")
+		return
+	}
+	f.dryRunSourceRanges[key+"/added"] = added
+	f.dryRunSourceRanges[key+"/removed"] = removed
+}
+
+func TestEnforceSourceRangesToServiceWithEmptyRangesKeepsLastApplied(t *testing.T) {
+	m := &fakeMetricsRecorder{}
+	svc, events, err := enforceFixture{config: service.Config{MetricsRecorder: m}, data: map[string]string{}, annotations: withConfigMap(nil), sourceRanges: []string{"10.0.0.0/8"}}.enforce()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"10.0.0.0/8"}, svc.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, []string{
+		"Warning SourceRangesEmptyGuard referenced sources hold no source ranges, applying [10.0.0.0/8] to Service test-service instead of allowing everyone (policy keep)",
+	}, events)
+	assert.Equal(t, map[string]int{"default/keep": 1}, m.emptySourceRangesGuard)
+}
+
+func TestEnforceSourceRangesToServiceWithEmptyRangesAndNothingToKeep(t *testing.T) {
+	svc, events, err := enforceFixture{config: service.Config{}, data: map[string]string{}, annotations: withConfigMap(nil), sourceRanges: nil}.enforce()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"127.0.0.1/32"}, svc.Spec.LoadBalancerSourceRanges)
+	if eventCount := len(events); eventCount != 2 {
+		t.Errorf("Expected 2 events when the guard applies the deny-all source range but got %d", eventCount)
+		return
+	}
+
+	assert.Equal(t, "Warning SourceRangesEmptyGuard referenced sources hold no source ranges, applying [127.0.0.1/32] to Service test-service instead of allowing everyone (policy keep)", events[0])
+}
+
+func TestEnforceSourceRangesToServiceWithEmptyRangesDenied(t *testing.T) {
+	m := &fakeMetricsRecorder{}
+	cfg := service.Config{EmptySourceRangesPolicy: service.EmptySourceRangesDeny, MetricsRecorder: m}
+	svc, _, err := enforceFixture{config: cfg, data: map[string]string{}, annotations: withConfigMap(nil), sourceRanges: []string{"10.0.0.0/8"}}.enforce()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"127.0.0.1/32"}, svc.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, map[string]int{"default/deny": 1}, m.emptySourceRangesGuard)
+}
+
+func TestEnforceSourceRangesToServiceWithEmptyRangesAllowedByAnnotation(t *testing.T) {
+	m := &fakeMetricsRecorder{}
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/empty-source-ranges-policy": "allow",
+	}
+	svc, events, err := enforceFixture{config: service.Config{MetricsRecorder: m}, data: map[string]string{}, annotations: withConfigMap(annotations), sourceRanges: []string{"10.0.0.0/8"}}.enforce()
+	assert.Nil(t, err)
+
+	assert.Empty(t, svc.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, []string{
+		"Normal SourceRangesEnforcementSuccessful Updated Service test-service LB source ranges, added: none; removed: 10.0.0.0/8",
+	}, events)
+	assert.Nil(t, m.emptySourceRangesGuard)
+}
+
+func TestEnforceSourceRangesToServiceWithInvalidEmptyRangesPolicy(t *testing.T) {
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/empty-source-ranges-policy": "open",
+	}
+	svc, events, err := enforceFixture{config: service.Config{}, data: map[string]string{}, annotations: withConfigMap(annotations), sourceRanges: []string{"10.0.0.0/8"}}.enforce()
+	assert.NotNil(t, err)
+
+	assert.Equal(t, []string{"10.0.0.0/8"}, svc.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, []string{
+		"Warning SourceRangesEnforcementFailed invalid annotation source-ranges.alpha.girao.net/empty-source-ranges-policy: invalid empty source ranges policy \"open\", must be one of keep, deny or allow (reason: permanent)",
+	}, events)
+}
+
+func TestParseEmptySourceRangesPolicy(t *testing.T) {
+	policy, err := service.ParseEmptySourceRangesPolicy("deny")
+	assert.Nil(t, err)
+	assert.Equal(t, service.EmptySourceRangesDeny, policy)
+
+	_, err = service.ParseEmptySourceRangesPolicy("")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ErrorReason classifies the errors enforcing source ranges, telling an API server
+// in trouble apart from a misconfigured Service. Every class is reported with the
+// SourceRangesEnforcementFailed event, the class ends the event message and labels the errors metric and logs.
+type ErrorReason string
+
+const (
+	// ErrorReasonTransient errors come from the API server, they are retried with backoff
+	ErrorReasonTransient ErrorReason = "transient"
+	// ErrorReasonPermanent errors come from missing sources or invalid annotations, they
+	// are not retried until the inputs change
+	ErrorReasonPermanent ErrorReason = "permanent"
+	// ErrorReasonPolicy errors come from references the controller doesn't allow, like
+	// ConfigMaps from namespaces that didn't allow the Service namespace
+	ErrorReasonPolicy ErrorReason = "policy"
+)
+
+// classifiedError is an error along with its reason
+type classifiedError struct {
+	reason ErrorReason
+	err    error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+// ReasonOf returns the reason of an error returned by a SourceRangeEnforcer, errors that
+// weren't classified come from the API server and are transient
+func ReasonOf(err error) ErrorReason {
+	if classified, ok := err.(*classifiedError); ok {
+		return classified.reason
+	}
+	return ErrorReasonTransient
+}
+
+// readErrorReason returns the reason of an error reading a source, which is permanent when the source doesn't exist
+func readErrorReason(err error) ErrorReason {
+	if apierrors.IsNotFound(err) {
+		return ErrorReasonPermanent
+	}
+	return ErrorReasonTransient
+}
+
+// fail emits a warning event about the error, ending with its reason, and returns it classified
+func (c *ConfigMapSourceRangeEnforcer) fail(svc *corev1.Service, reason ErrorReason, err error, message string) error {
+	message = fmt.Sprintf("%s (reason: %s)", message, reason)
+	c.recorder.Event(svc, corev1.EventTypeWarning, "SourceRangesEnforcementFailed", message)
+	return &classifiedError{reason: reason, err: err}
+}
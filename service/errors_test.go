@@ -0,0 +1,89 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jeffersongirao/source-ranges-controller/service"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubetesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestReasonOfUnclassifiedErrors(t *testing.T) {
+	assert.Equal(t, service.ErrorReasonTransient, service.ReasonOf(errors.New("connection refused")))
+}
+
+func TestEnforceSourceRangesToServiceRecordsErrorReasons(t *testing.T) {
+	tests := []struct {
+		name           string
+		annotations    map[string]string
+		objects        []runtime.Object
+		configMapError error
+		expectedReason service.ErrorReason
+		expectedEvent  string
+	}{
+		{
+			name:           "missing ConfigMap",
+			annotations:    map[string]string{"source-ranges.alpha.girao.net/config-map": "vendors"},
+			expectedReason: service.ErrorReasonPermanent,
+			expectedEvent:  "Warning SourceRangesEnforcementFailed could not read ConfigMap vendors: configmaps \"vendors\" not found (reason: permanent)",
+		},
+		{
+			name:           "API server error reading ConfigMap",
+			annotations:    map[string]string{"source-ranges.alpha.girao.net/config-map": "vendors"},
+			configMapError: apierrors.NewServiceUnavailable("API server down"),
+			expectedReason: service.ErrorReasonTransient,
+			expectedEvent:  "Warning SourceRangesEnforcementFailed could not read ConfigMap vendors: API server down (reason: transient)",
+		},
+		{
+			name:           "invalid annotation",
+			annotations:    map[string]string{"source-ranges.alpha.girao.net/config-map": "vendors", "source-ranges.alpha.girao.net/dry-run": "maybe"},
+			expectedReason: service.ErrorReasonPermanent,
+			expectedEvent:  "Warning SourceRangesEnforcementFailed invalid annotation source-ranges.alpha.girao.net/dry-run: \"maybe\" is not a boolean (reason: permanent)",
+		},
+		{
+			name:        "ConfigMap not allowing the namespace",
+			annotations: map[string]string{"source-ranges.alpha.girao.net/config-map": "network-policies/offices"},
+			objects: []runtime.Object{&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "network-policies", Name: "offices"},
+			}},
+			expectedReason: service.ErrorReasonPolicy,
+			expectedEvent:  "Warning SourceRangesEnforcementFailed could not use ConfigMap network-policies/offices: namespace default is not allowed to reference ConfigMap network-policies/offices (reason: policy)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			k8sCli := newFakeClientset(test.objects...)
+			if test.configMapError != nil {
+				k8sCli.PrependReactor("get", "configmaps", func(action kubetesting.Action) (bool, runtime.Object, error) {
+					return true, nil, test.configMapError
+				})
+			}
+
+			svc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:   metav1.NamespaceDefault,
+					Name:        "test-service",
+					Annotations: test.annotations,
+				},
+			}
+			k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+			m := &fakeMetricsRecorder{}
+			recorder := record.NewFakeRecorder(1)
+			e := service.NewConfigMapSourceRangeEnforcerWithConfig(service.Config{MetricsRecorder: m}, k8sCli, recorder)
+
+			err := e.EnforceSourceRangesToService(svc)
+			assert.NotNil(t, err)
+			assert.Equal(t, test.expectedReason, service.ReasonOf(err))
+			assert.Equal(t, map[string]int{"default/" + string(test.expectedReason): 1}, m.enforcementErrors)
+			assert.Equal(t, []string{test.expectedEvent}, collectEvents(recorder.Events))
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jeffersongirao/source-ranges-controller/cidr"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// The annotation used for choosing how the source ranges are applied to a Service
+	modeAnnotationKey = "source-ranges.alpha.girao.net/mode"
+
+	// The annotation used for recording the source ranges added by the controller in merge mode
+	managedSourceRangesAnnotationKey = "source-ranges.alpha.girao.net/managed-source-ranges"
+)
+
+// mode tells how the source ranges are applied to a Service
+type mode string
+
+const (
+	// modeReplace replaces the Service source ranges with the ones from the sources
+	modeReplace mode = "replace"
+	// modeMerge adds the source ranges from the sources keeping the ones added by hand
+	modeMerge mode = "merge"
+)
+
+// serviceMode returns the mode from the Service annotation, replace by default
+func (c *ConfigMapSourceRangeEnforcer) serviceMode(svc *corev1.Service) (mode, error) {
+	value, ok := svc.ObjectMeta.Annotations[modeAnnotationKey]
+	if !ok {
+		return modeReplace, nil
+	}
+
+	switch m := mode(value); m {
+	case modeReplace, modeMerge:
+		return m, nil
+	}
+
+	err := fmt.Errorf("invalid mode %q, must be one of %s or %s", value, modeReplace, modeMerge)
+	message := fmt.Sprintf("invalid annotation %s: %v", modeAnnotationKey, err)
+	return "", c.fail(svc, ErrorReasonPermanent, err, message)
+}
+
+// mergeSourceRanges returns the source ranges added by hand to the Service merged with the given ones,
+// along with the ones the controller manages, that is the given ones not added by hand
+func mergeSourceRanges(svc *corev1.Service, sourceRanges []string) ([]string, []string) {
+	previous := cidr.NewSet(previousManagedSourceRanges(svc)...)
+
+	unmanaged := cidr.NewSet()
+	for _, sourceRange := range svc.Spec.LoadBalancerSourceRanges {
+		if !previous.Has(sourceRange) {
+			unmanaged.Insert(sourceRange)
+		}
+	}
+
+	managed := []string{}
+	merged := cidr.NewSet(unmanaged.List()...)
+	for _, sourceRange := range sourceRanges {
+		if !unmanaged.Has(sourceRange) {
+			managed = append(managed, sourceRange)
+		}
+		merged.Insert(sourceRange)
+	}
+	return merged.List(), managed
+}
+
+// managedSourceRanges returns the source ranges recorded as added by the controller
+func managedSourceRanges(svc *corev1.Service) []string {
+	return splitAnnotation(svc.ObjectMeta.Annotations[managedSourceRangesAnnotationKey])
+}
+
+// previousManagedSourceRanges returns the source ranges added by the controller. Owned Services switched from
+// replace to merge mode have no record yet, every source range but the original ones was added by the controller.
+func previousManagedSourceRanges(svc *corev1.Service) []string {
+	if _, ok := svc.ObjectMeta.Annotations[managedSourceRangesAnnotationKey]; ok || !isOwned(svc) {
+		return managedSourceRanges(svc)
+	}
+
+	original := cidr.NewSet(splitAnnotation(svc.ObjectMeta.Annotations[originalSourceRangesAnnotationKey])...)
+	var managed []string
+	for _, sourceRange := range svc.Spec.LoadBalancerSourceRanges {
+		if !original.Has(sourceRange) {
+			managed = append(managed, sourceRange)
+		}
+	}
+	return managed
+}
+
+// managedSourceRangesChanged tells if the recorded source ranges added by the controller differ from the given ones
+func managedSourceRangesChanged(svc *corev1.Service, managed []string) bool {
+	current, ok := svc.ObjectMeta.Annotations[managedSourceRangesAnnotationKey]
+	if managed == nil {
+		return ok
+	}
+	return !ok || current != strings.Join(managed, ",")
+}
+
+// setManagedSourceRanges records the source ranges added by the controller, nil removes the record
+func setManagedSourceRanges(svc *corev1.Service, managed []string) {
+	if managed == nil {
+		delete(svc.ObjectMeta.Annotations, managedSourceRangesAnnotationKey)
+		return
+	}
+	svc.ObjectMeta.Annotations[managedSourceRangesAnnotationKey] = strings.Join(managed, ",")
+}
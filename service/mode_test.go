@@ -0,0 +1,118 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnforceSourceRangesToServiceInMergeMode(t *testing.T) {
+	data := map[string]string{
+		"test":  "10.0.0.0/8",
+		"test2": "192.168.0.0/16",
+	}
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/mode":                  "merge",
+		"source-ranges.alpha.girao.net/managed-source-ranges": "10.0.0.0/8,172.16.0.0/12",
+	}
+	svc, events, err := enforceFixture{data: data, annotations: withConfigMap(annotations), sourceRanges: []string{"10.0.0.0/8", "172.16.0.0/12", "123.123.123.123/32"}}.enforce()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"10.0.0.0/8", "123.123.123.123/32", "192.168.0.0/16"}, svc.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, "10.0.0.0/8,192.168.0.0/16", svc.ObjectMeta.Annotations["source-ranges.alpha.girao.net/managed-source-ranges"])
+	assert.Equal(t, []string{
+		"Normal SourceRangesEnforcementSuccessful Updated Service test-service LB source ranges, added: 192.168.0.0/16 (ConfigMap test-config key test2); removed: 172.16.0.0/12",
+	}, events)
+}
+
+func TestEnforceSourceRangesToServiceInMergeModeTakingOwnership(t *testing.T) {
+	data := map[string]string{
+		"test":  "10.0.0.0/8",
+		"test2": "192.168.0.0/16",
+	}
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/mode": "merge",
+	}
+	svc, _, err := enforceFixture{data: data, annotations: withConfigMap(annotations), sourceRanges: []string{"10.0.0.0/8", "123.123.123.123/32"}}.enforce()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"10.0.0.0/8", "123.123.123.123/32", "192.168.0.0/16"}, svc.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, "192.168.0.0/16", svc.ObjectMeta.Annotations["source-ranges.alpha.girao.net/managed-source-ranges"])
+}
+
+func TestEnforceSourceRangesToServiceSwitchedToMergeMode(t *testing.T) {
+	data := map[string]string{
+		"test": "192.168.0.0/16",
+	}
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/mode":                   "merge",
+		"source-ranges.alpha.girao.net/original-source-ranges": "123.123.123.123/32",
+	}
+	svc, _, err := enforceFixture{data: data, annotations: withConfigMap(annotations), sourceRanges: []string{"10.0.0.0/8", "123.123.123.123/32", "172.16.0.0/12"}}.enforce()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"123.123.123.123/32", "192.168.0.0/16"}, svc.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, "192.168.0.0/16", svc.ObjectMeta.Annotations["source-ranges.alpha.girao.net/managed-source-ranges"])
+}
+
+func TestEnforceSourceRangesToServiceInMergeModeWithNothingManaged(t *testing.T) {
+	data := map[string]string{
+		"test": "123.123.123.123/32",
+	}
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/mode": "merge",
+	}
+	svc, _, err := enforceFixture{data: data, annotations: withConfigMap(annotations), sourceRanges: []string{"123.123.123.123/32"}}.enforce()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"123.123.123.123/32"}, svc.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, "", svc.ObjectMeta.Annotations["source-ranges.alpha.girao.net/managed-source-ranges"])
+	assert.Contains(t, svc.ObjectMeta.Annotations, "source-ranges.alpha.girao.net/managed-source-ranges")
+}
+
+func TestEnforceSourceRangesToServiceInMergeModeWithNoChanges(t *testing.T) {
+	data := map[string]string{
+		"test": "10.0.0.0/8",
+	}
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/mode":                  "merge",
+		"source-ranges.alpha.girao.net/managed-source-ranges": "10.0.0.0/8",
+	}
+	_, events, err := enforceFixture{data: data, annotations: withConfigMap(annotations), sourceRanges: []string{"10.0.0.0/8", "123.123.123.123/32"}}.enforce()
+	assert.Nil(t, err)
+
+	if eventCount := len(events); eventCount != 0 {
+		t.Errorf("Expected 0 event when merged source ranges didn't change but got %d", eventCount)
+	}
+}
+
+func TestEnforceSourceRangesToServiceInReplaceMode(t *testing.T) {
+	data := map[string]string{
+		"test": "10.0.0.0/8",
+	}
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/mode":                  "replace",
+		"source-ranges.alpha.girao.net/managed-source-ranges": "10.0.0.0/8",
+	}
+	svc, _, err := enforceFixture{data: data, annotations: withConfigMap(annotations), sourceRanges: []string{"10.0.0.0/8", "123.123.123.123/32"}}.enforce()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"10.0.0.0/8"}, svc.Spec.LoadBalancerSourceRanges)
+	assert.NotContains(t, svc.ObjectMeta.Annotations, "source-ranges.alpha.girao.net/managed-source-ranges")
+}
+
+func TestEnforceSourceRangesToServiceWithInvalidMode(t *testing.T) {
+	data := map[string]string{
+		"test": "10.0.0.0/8",
+	}
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/mode": "append",
+	}
+	svc, events, err := enforceFixture{data: data, annotations: withConfigMap(annotations), sourceRanges: []string{"123.123.123.123/32"}}.enforce()
+	assert.NotNil(t, err)
+
+	assert.Equal(t, []string{"123.123.123.123/32"}, svc.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, []string{
+		"Warning SourceRangesEnforcementFailed invalid annotation source-ranges.alpha.girao.net/mode: invalid mode \"append\", must be one of replace or merge (reason: permanent)",
+	}, events)
+}
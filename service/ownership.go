@@ -0,0 +1,71 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jeffersongirao/source-ranges-controller/cidr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// The annotation used for recording the Service source ranges from before the controller took ownership
+	originalSourceRangesAnnotationKey = "source-ranges.alpha.girao.net/original-source-ranges"
+)
+
+// isOwned tells if the controller took ownership of the Service source ranges
+func isOwned(svc *corev1.Service) bool {
+	_, ok := svc.ObjectMeta.Annotations[originalSourceRangesAnnotationKey]
+	return ok
+}
+
+// takeOwnership records the Service source ranges the first time the controller manages them
+func takeOwnership(svc *corev1.Service) {
+	if !isOwned(svc) {
+		svc.ObjectMeta.Annotations[originalSourceRangesAnnotationKey] = strings.Join(svc.Spec.LoadBalancerSourceRanges, ",")
+	}
+}
+
+// releaseService gives the Service source ranges back once it doesn't reference any source anymore,
+// restoring the ones from before the controller took ownership. In merge mode only the source ranges
+// added by the controller are removed, so the ones added by hand meanwhile are kept.
+func (c *ConfigMapSourceRangeEnforcer) releaseService(original, svc *corev1.Service, dryRun bool) error {
+	sourceRanges := splitAnnotation(svc.ObjectMeta.Annotations[originalSourceRangesAnnotationKey])
+	if svc.ObjectMeta.Annotations[modeAnnotationKey] == string(modeMerge) {
+		managed := cidr.NewSet(previousManagedSourceRanges(svc)...)
+		sourceRanges = nil
+		for _, sourceRange := range svc.Spec.LoadBalancerSourceRanges {
+			if !managed.Has(sourceRange) {
+				sourceRanges = append(sourceRanges, sourceRange)
+			}
+		}
+	}
+
+	if dryRun {
+		diff := newSourceRangesDiff(svc.Spec.LoadBalancerSourceRanges, sourceRanges, nil)
+		diff.confidential = isConfidentialDiff(svc, diff)
+		c.recordDryRun(svc, diff)
+		return nil
+	}
+
+	svc.Spec.LoadBalancerSourceRanges = sourceRanges
+	delete(svc.ObjectMeta.Annotations, originalSourceRangesAnnotationKey)
+	delete(svc.ObjectMeta.Annotations, managedSourceRangesAnnotationKey)
+	delete(svc.ObjectMeta.Annotations, confidentialAnnotationKey)
+	delete(svc.ObjectMeta.Annotations, descriptionsAnnotationKey)
+	err := c.patchService(original, svc)
+	if apierrors.IsConflict(err) {
+		return err
+	} else if err != nil {
+		message := fmt.Sprintf("could not restore Service %s: %v", svc.ObjectMeta.Name, err)
+		return c.fail(svc, ErrorReasonTransient, err, message)
+	}
+
+	c.ForgetService(svc.ObjectMeta.Namespace, svc.ObjectMeta.Name)
+
+	reason := "SourceRangesManagementEnded"
+	message := fmt.Sprintf("Stopped managing Service %s, restored LB source ranges: %v", svc.ObjectMeta.Name, sourceRanges)
+	c.recorder.Event(svc, corev1.EventTypeNormal, reason, message)
+	return nil
+}
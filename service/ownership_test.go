@@ -0,0 +1,81 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnforceSourceRangesToServiceTakesOwnership(t *testing.T) {
+	data := map[string]string{
+		"test": "10.0.0.0/8",
+	}
+	svc, _, err := enforceFixture{data: data, annotations: withConfigMap(nil), sourceRanges: []string{"123.123.123.123/32", "192.168.0.0/16"}}.enforce()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"10.0.0.0/8"}, svc.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, "123.123.123.123/32,192.168.0.0/16", svc.ObjectMeta.Annotations["source-ranges.alpha.girao.net/original-source-ranges"])
+}
+
+func TestEnforceSourceRangesToServiceKeepsOriginalSourceRanges(t *testing.T) {
+	data := map[string]string{
+		"test": "10.0.0.0/8",
+	}
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/original-source-ranges": "",
+	}
+	svc, _, err := enforceFixture{data: data, annotations: withConfigMap(annotations), sourceRanges: []string{"123.123.123.123/32"}}.enforce()
+	assert.Nil(t, err)
+
+	assert.Equal(t, "", svc.ObjectMeta.Annotations["source-ranges.alpha.girao.net/original-source-ranges"])
+}
+
+func TestEnforceSourceRangesToServiceRestoresOriginalSourceRanges(t *testing.T) {
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/original-source-ranges":    "123.123.123.123/32,192.168.0.0/16",
+		"source-ranges.alpha.girao.net/managed-source-ranges":     "10.0.0.0/8",
+		"source-ranges.alpha.girao.net/source-range-descriptions": `{"10.0.0.0/8":"VPN"}`,
+	}
+	svc, events, err := enforceFixture{annotations: annotations, sourceRanges: []string{"10.0.0.0/8"}}.enforce()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"123.123.123.123/32", "192.168.0.0/16"}, svc.Spec.LoadBalancerSourceRanges)
+	assert.Empty(t, svc.ObjectMeta.Annotations)
+	assert.Equal(t, []string{
+		"Normal SourceRangesManagementEnded Stopped managing Service test-service, restored LB source ranges: [123.123.123.123/32 192.168.0.0/16]",
+	}, events)
+}
+
+func TestEnforceSourceRangesToServiceRestoresInMergeMode(t *testing.T) {
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/mode":                   "merge",
+		"source-ranges.alpha.girao.net/original-source-ranges": "123.123.123.123/32",
+		"source-ranges.alpha.girao.net/managed-source-ranges":  "10.0.0.0/8",
+	}
+	svc, _, err := enforceFixture{annotations: annotations, sourceRanges: []string{"10.0.0.0/8", "123.123.123.123/32", "192.168.0.0/16"}}.enforce()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"123.123.123.123/32", "192.168.0.0/16"}, svc.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, map[string]string{"source-ranges.alpha.girao.net/mode": "merge"}, svc.ObjectMeta.Annotations)
+}
+
+func TestEnforceSourceRangesToServiceRestoresAfterSwitchingToMergeMode(t *testing.T) {
+	annotations := map[string]string{
+		"source-ranges.alpha.girao.net/mode":                   "merge",
+		"source-ranges.alpha.girao.net/original-source-ranges": "123.123.123.123/32",
+	}
+	svc, _, err := enforceFixture{annotations: annotations, sourceRanges: []string{"10.0.0.0/8", "123.123.123.123/32"}}.enforce()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"123.123.123.123/32"}, svc.Spec.LoadBalancerSourceRanges)
+}
+
+func TestEnforceSourceRangesToServiceNotOwned(t *testing.T) {
+	svc, events, err := enforceFixture{annotations: nil, sourceRanges: []string{"10.0.0.0/8"}}.enforce()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"10.0.0.0/8"}, svc.Spec.LoadBalancerSourceRanges)
+	if eventCount := len(events); eventCount != 0 {
+		t.Errorf("Expected 0 event when service was never managed but got %d", eventCount)
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// controllerAnnotationKeys are the Service annotations owned by the controller
+var controllerAnnotationKeys = []string{
+	originalSourceRangesAnnotationKey,
+	managedSourceRangesAnnotationKey,
+	confidentialAnnotationKey,
+	descriptionsAnnotationKey,
+}
+
+// servicePatch is a JSON merge patch touching only the Service source ranges and the controller annotations
+type servicePatch struct {
+	Metadata servicePatchMetadata `json:"metadata"`
+	Spec     servicePatchSpec     `json:"spec"`
+}
+
+type servicePatchMetadata struct {
+	ResourceVersion string             `json:"resourceVersion,omitempty"`
+	Annotations     map[string]*string `json:"annotations,omitempty"`
+}
+
+type servicePatchSpec struct {
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges"`
+}
+
+// patchService applies the source ranges and controller annotations of svc to the Service as a JSON merge patch.
+// The resource version of original is sent along so a Service changed meanwhile results in a conflict.
+func (c *ConfigMapSourceRangeEnforcer) patchService(original, svc *corev1.Service) error {
+	patch := servicePatch{
+		Metadata: servicePatchMetadata{
+			ResourceVersion: original.ObjectMeta.ResourceVersion,
+			Annotations:     map[string]*string{},
+		},
+		Spec: servicePatchSpec{
+			LoadBalancerSourceRanges: svc.Spec.LoadBalancerSourceRanges,
+		},
+	}
+
+	for _, key := range controllerAnnotationKeys {
+		if value, ok := svc.ObjectMeta.Annotations[key]; ok {
+			patch.Metadata.Annotations[key] = &value
+		} else if _, ok := original.ObjectMeta.Annotations[key]; ok {
+			patch.Metadata.Annotations[key] = nil
+		}
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.CoreV1().Services(svc.ObjectMeta.Namespace).Patch(svc.ObjectMeta.Name, types.MergePatchType, data)
+	return err
+}
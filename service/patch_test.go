@@ -0,0 +1,111 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/jeffersongirao/source-ranges-controller/service"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubetesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestEnforceSourceRangesToServicePatchesService(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-config",
+		},
+		Data: map[string]string{
+			"test": "10.0.0.0/8",
+		},
+	}
+	k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Create(cm)
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       metav1.NamespaceDefault,
+			Name:            "test-service",
+			ResourceVersion: "42",
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/config-map": "test-config",
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			LoadBalancerSourceRanges: []string{"123.123.123.123/32"},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	var patches []string
+	k8sCli.PrependReactor("patch", "services", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		patches = append(patches, string(action.(kubetesting.PatchAction).GetPatch()))
+		return false, nil, nil
+	})
+
+	recorder := record.NewFakeRecorder(1)
+	e := service.NewConfigMapSourceRangeEnforcer(k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		`{"metadata":{"resourceVersion":"42","annotations":{"source-ranges.alpha.girao.net/original-source-ranges":"123.123.123.123/32"}},"spec":{"loadBalancerSourceRanges":["10.0.0.0/8"]}}`,
+	}, patches)
+	assert.Equal(t, []string{"123.123.123.123/32"}, svc.Spec.LoadBalancerSourceRanges, "the given Service must not be mutated")
+	assert.NotContains(t, svc.ObjectMeta.Annotations, "source-ranges.alpha.girao.net/original-source-ranges", "the given Service must not be mutated")
+
+	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	assert.Equal(t, []string{"10.0.0.0/8"}, new.Spec.LoadBalancerSourceRanges)
+}
+
+func TestEnforceSourceRangesToServiceRetriesOnConflict(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-config",
+		},
+		Data: map[string]string{
+			"test": "10.0.0.0/8",
+		},
+	}
+	k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Create(cm)
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Name:      "test-service",
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/config-map": "test-config",
+			},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	conflicts := 2
+	k8sCli.PrependReactor("patch", "services", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		if conflicts == 0 {
+			return false, nil, nil
+		}
+		conflicts--
+		return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "services"}, svc.ObjectMeta.Name, nil)
+	})
+
+	recorder := record.NewFakeRecorder(1)
+	e := service.NewConfigMapSourceRangeEnforcer(k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.Nil(t, err)
+
+	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	assert.Equal(t, []string{"10.0.0.0/8"}, new.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, []string{
+		"Normal SourceRangesEnforcementSuccessful Updated Service test-service LB source ranges, added: 10.0.0.0/8 (ConfigMap test-config key test); removed: none",
+	}, collectEvents(recorder.Events))
+}
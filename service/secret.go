@@ -0,0 +1,133 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jeffersongirao/source-ranges-controller/cidr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// The annotation used for figuring out Secrets to get confidential loadBalancerSourceRanges from,
+	// multiple Secrets can be given separated by commas and they must live in the Service namespace
+	secretAnnotationKey = "source-ranges.alpha.girao.net/secret"
+
+	// The annotation used for recording that source ranges from Secrets were applied to a Service, so they
+	// aren't echoed once the Service stops referencing the Secrets until it's released
+	confidentialAnnotationKey = "source-ranges.alpha.girao.net/confidential-source-ranges"
+)
+
+// SecretGetter gets the Secrets holding confidential loadBalancerSourceRanges
+type SecretGetter interface {
+	GetSecret(namespace, name string) (*corev1.Secret, error)
+}
+
+// clientSecretGetter gets Secrets straight from the API server, so the controller only needs
+// to be allowed to get the referenced Secrets instead of listing and watching every Secret
+type clientSecretGetter struct {
+	client kubernetes.Interface
+}
+
+func (c *clientSecretGetter) GetSecret(namespace, name string) (*corev1.Secret, error) {
+	return c.client.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+}
+
+// SecretReferences returns the namespace/name keys of the Secrets referenced by the Service annotation
+func SecretReferences(svc *corev1.Service) []string {
+	var refs []string
+	for _, name := range secretNames(svc) {
+		refs = append(refs, svc.ObjectMeta.Namespace+"/"+name)
+	}
+	return refs
+}
+
+// secretValues returns the valid source ranges of every Secret referenced by the Service. The Secret
+// data goes through the same parsing and validation as the ConfigMap data, but the events only name
+// its keys, leaving out the descriptions as well.
+func (c *ConfigMapSourceRangeEnforcer) secretValues(svc *corev1.Service) ([]sourceRange, error) {
+	var values []sourceRange
+	for _, name := range secretNames(svc) {
+		if strings.Contains(name, "/") {
+			err := fmt.Errorf("%q is not a name, Secrets can only be referenced from the Service namespace", name)
+			message := fmt.Sprintf("invalid Secret reference: %v", err)
+			return nil, c.fail(svc, ErrorReasonPermanent, err, message)
+		}
+
+		secret, err := c.secrets.GetSecret(svc.ObjectMeta.Namespace, name)
+		if err != nil {
+			message := fmt.Sprintf("could not read Secret %s: %v", name, err)
+			return nil, c.fail(svc, readErrorReason(err), err, message)
+		}
+
+		data := make(map[string]string, len(secret.Data))
+		for key, value := range secret.Data {
+			data[key] = string(value)
+		}
+
+		secretValues, err := configMapValues(data, time.Now())
+		if err != nil {
+			message := fmt.Sprintf("invalid source ranges document in Secret %s %v", name, err)
+			return nil, c.fail(svc, ErrorReasonPermanent, err, message)
+		}
+		if len(secretValues.invalidKeys) != 0 {
+			reason := "SourceRangesValidationFailed"
+			message := fmt.Sprintf("ignored %d invalid source ranges from Secret %s keys: %s", secretValues.rejected, name, strings.Join(secretValues.invalidKeys, ", "))
+			c.recorder.Event(svc, corev1.EventTypeWarning, reason, message)
+			c.metrics.AddValidationRejections(svc.ObjectMeta.Namespace, "Secret", secretValues.rejected)
+		}
+		for _, value := range secretValues.valid {
+			values = append(values, sourceRange{value: value.value, origin: fmt.Sprintf("Secret %s key %s", name, value.origin)})
+		}
+	}
+	return values, nil
+}
+
+// secretNames returns the de-duplicated Secret names from the comma-separated annotation
+func secretNames(svc *corev1.Service) []string {
+	return splitAnnotation(svc.ObjectMeta.Annotations[secretAnnotationKey])
+}
+
+// isConfidential tells if the Service source ranges may come from Secrets, either because the Service
+// references Secrets or because it did since the controller took ownership
+func isConfidential(svc *corev1.Service) bool {
+	_, ok := svc.ObjectMeta.Annotations[confidentialAnnotationKey]
+	return ok || len(secretNames(svc)) != 0
+}
+
+// isConfidentialDiff tells if the diff may echo source ranges from Secrets, in which case the events and
+// logs only report how many source ranges changed and where they come from. Once the Service doesn't
+// reference Secrets anymore only the source ranges it had before the controller took ownership can be
+// told apart from the ones that came from Secrets.
+func isConfidentialDiff(svc *corev1.Service, diff sourceRangesDiff) bool {
+	if len(secretNames(svc)) != 0 {
+		return true
+	}
+	if _, ok := svc.ObjectMeta.Annotations[confidentialAnnotationKey]; !ok {
+		return false
+	}
+
+	original := cidr.NewSet(splitAnnotation(svc.ObjectMeta.Annotations[originalSourceRangesAnnotationKey])...)
+	for _, sourceRange := range diff.removed {
+		if !original.Has(sourceRange) {
+			return true
+		}
+	}
+	return false
+}
+
+// needsConfidentialMark tells if the Service references Secrets but isn't marked as having source ranges from them yet
+func needsConfidentialMark(svc *corev1.Service) bool {
+	_, ok := svc.ObjectMeta.Annotations[confidentialAnnotationKey]
+	return !ok && len(secretNames(svc)) != 0
+}
+
+// markConfidential records that source ranges from Secrets are applied to the Service
+func markConfidential(svc *corev1.Service) {
+	if needsConfidentialMark(svc) {
+		svc.ObjectMeta.Annotations[confidentialAnnotationKey] = "true"
+	}
+}
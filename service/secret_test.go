@@ -0,0 +1,198 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jeffersongirao/source-ranges-controller/service"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestEnforceSourceRangesToServiceWithSecret(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Name:      "partners",
+		},
+		Data: map[string][]byte{
+			"acme":    []byte("203.0.113.7"),
+			"globex":  []byte("198.51.100.0/24"),
+			"initech": []byte("not-a-cidr"),
+		},
+	}
+	k8sCli.CoreV1().Secrets(metav1.NamespaceDefault).Create(secret)
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/secret": "partners",
+			},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	recorder := record.NewFakeRecorder(2)
+	e := service.NewConfigMapSourceRangeEnforcer(k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.NoError(t, err)
+
+	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	assert.Equal(t, []string{"198.51.100.0/24", "203.0.113.7/32"}, new.Spec.LoadBalancerSourceRanges)
+
+	events := collectEvents(recorder.Events)
+	assert.Equal(t, []string{
+		"Warning SourceRangesValidationFailed ignored 1 invalid source ranges from Secret partners keys: initech",
+		"Normal SourceRangesEnforcementSuccessful Updated Service  LB source ranges, added: 2 (Secret partners key globex, Secret partners key acme); removed: 0",
+	}, events)
+	for _, event := range events {
+		for _, value := range []string{"203.0.113.7", "198.51.100", "not-a-cidr"} {
+			assert.False(t, strings.Contains(event, value), "event %q echoes the Secret value %s", event, value)
+		}
+	}
+}
+
+func TestEnforceSourceRangesToServiceAfterRemovingSecret(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Name:      "partners",
+		},
+		Data: map[string][]byte{
+			"acme": []byte("203.0.113.7"),
+		},
+	}
+	k8sCli.CoreV1().Secrets(metav1.NamespaceDefault).Create(secret)
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Name:      "test-config",
+		},
+		Data: map[string]string{
+			"office": "10.0.0.0/8",
+		},
+	}
+	k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Create(cm)
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Name:      "test-service",
+			Annotations: withConfigMap(map[string]string{
+				"source-ranges.alpha.girao.net/secret": "partners",
+			}),
+		},
+		Spec: corev1.ServiceSpec{
+			LoadBalancerSourceRanges: []string{"192.168.0.0/16"},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	recorder := record.NewFakeRecorder(10)
+	e := service.NewConfigMapSourceRangeEnforcer(k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.NoError(t, err)
+
+	svc, _ = k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	assert.Equal(t, "true", svc.ObjectMeta.Annotations["source-ranges.alpha.girao.net/confidential-source-ranges"])
+	collectEvents(recorder.Events)
+
+	delete(svc.ObjectMeta.Annotations, "source-ranges.alpha.girao.net/secret")
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Update(svc)
+
+	err = e.EnforceSourceRangesToService(svc)
+	assert.NoError(t, err)
+
+	svc, _ = k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	assert.Equal(t, []string{"10.0.0.0/8"}, svc.Spec.LoadBalancerSourceRanges)
+	assert.Equal(t, []string{
+		"Normal SourceRangesEnforcementSuccessful Updated Service test-service LB source ranges, added: 0; removed: 1",
+	}, collectEvents(recorder.Events))
+
+	delete(svc.ObjectMeta.Annotations, "source-ranges.alpha.girao.net/config-map")
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Update(svc)
+
+	dryRun := service.NewConfigMapSourceRangeEnforcerWithConfig(service.Config{DryRun: true}, k8sCli, recorder)
+	err = dryRun.EnforceSourceRangesToService(svc)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"Normal SourceRangesDryRun dry run, Service test-service LB source ranges would be updated, added: 1; removed: 1",
+	}, collectEvents(recorder.Events))
+}
+
+func TestEnforceSourceRangesToServiceWithSecretFromOtherNamespace(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/secret": "network-policies/partners",
+			},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	recorder := record.NewFakeRecorder(1)
+	e := service.NewConfigMapSourceRangeEnforcer(k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.Error(t, err)
+	assert.Equal(t, service.ErrorReasonPermanent, service.ReasonOf(err))
+
+	events := collectEvents(recorder.Events)
+	if assert.Len(t, events, 1) {
+		assert.True(t, strings.HasPrefix(events[0], "Warning SourceRangesEnforcementFailed invalid Secret reference"))
+	}
+}
+
+func TestEnforceSourceRangesToServiceWithNonExistingSecret(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/secret": "partners",
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	recorder := record.NewFakeRecorder(1)
+	e := service.NewConfigMapSourceRangeEnforcer(k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.Equal(t, service.ErrorReasonPermanent, service.ReasonOf(err))
+
+	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	assert.Equal(t, []string{"10.0.0.0/8"}, new.Spec.LoadBalancerSourceRanges)
+}
+
+func TestSecretReferences(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team-a",
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/secret": "partners, vendors,partners",
+			},
+		},
+	}
+
+	assert.Equal(t, []string{"team-a/partners", "team-a/vendors"}, service.SecretReferences(svc))
+	assert.Empty(t, service.SecretReferences(&corev1.Service{}))
+}
@@ -2,102 +2,433 @@ package service
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/jeffersongirao/source-ranges-controller/cidr"
+	"github.com/jeffersongirao/source-ranges-controller/log"
+	"github.com/jeffersongirao/source-ranges-controller/metrics"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 )
 
 const (
-	// The annotation used for figuring out configmap to get loadBalancerSourceRanges from
+	// The annotation used for figuring out configmaps to get loadBalancerSourceRanges from,
+	// multiple configmaps can be given separated by commas and configmaps from other
+	// namespaces are referenced as namespace/name
 	configMapAnnotationKey = "source-ranges.alpha.girao.net/config-map"
+
+	// The suffix of the ConfigMap keys whose source ranges are left out, so a key can be
+	// disabled without deleting it
+	disabledKeySuffix = ".disabled"
 )
 
 // SourceRangeEnforcer enforces loadBalancerSourceRanges
 type SourceRangeEnforcer interface {
 	EnforceSourceRangesToService(svc *corev1.Service) error
+	// ForgetService drops what is kept about a deleted Service, like its metrics
+	ForgetService(namespace, name string)
+}
+
+// ConfigMapGetter gets the ConfigMaps holding loadBalancerSourceRanges
+type ConfigMapGetter interface {
+	GetConfigMap(namespace, name string) (*corev1.ConfigMap, error)
+}
+
+// Config is the ConfigMapSourceRangeEnforcer configuration
+type Config struct {
+	// ConfigMapGetter is used to read ConfigMaps, when nil they are read from the API server
+	ConfigMapGetter ConfigMapGetter
+	// SourceRangeSetGetter is used to read SourceRangeSets, when nil SourceRangeSets can't be referenced
+	SourceRangeSetGetter SourceRangeSetGetter
+	// SecretGetter is used to read Secrets, when nil they are read from the API server
+	SecretGetter SecretGetter
+	// Aggregate merges adjacent and overlapping source ranges before applying them
+	Aggregate bool
+	// MaxSourceRanges is the number of source ranges above which a warning is emitted, 0 means no limit
+	MaxSourceRanges int
+	// EmptySourceRangesPolicy is applied when the sources hold no source ranges, keep by default
+	EmptySourceRangesPolicy EmptySourceRangesPolicy
+	// MetricsRecorder records the enforcement metrics, when nil no metrics are recorded
+	MetricsRecorder metrics.Recorder
+	// DryRun reports the changes to the Services instead of applying them
+	DryRun bool
+	// Logger logs the changes made to the Services, when nil nothing is logged
+	Logger log.Logger
 }
 
 // ConfigMapSourceRangeEnforcer enforces that loadBalancerSourceRanges to a Service
 // from a ConfigMap specified by annotation
 type ConfigMapSourceRangeEnforcer struct {
-	client   kubernetes.Interface
-	recorder record.EventRecorder
+	client          kubernetes.Interface
+	configMaps      ConfigMapGetter
+	sourceRangeSets SourceRangeSetGetter
+	secrets         SecretGetter
+	recorder        record.EventRecorder
+	aggregate       bool
+	maxSourceRanges int
+	emptyPolicy     EmptySourceRangesPolicy
+	metrics         metrics.Recorder
+	dryRun          bool
+	logger          log.Logger
+
+	// dryRuns holds the last dry run changes reported for each Service
+	mu      sync.Mutex
+	dryRuns map[string]string
 }
 
-// EnforceSourceRangesToService enforces loadBalancerSourceRanges to a Service based on ConfigMap from annotation
+// EnforceSourceRangesToService enforces loadBalancerSourceRanges to a Service based on the ConfigMaps, Secrets and SourceRangeSets from annotations.
+// When any of the referenced sources can't be read the Service is left untouched.
+// Once the annotations are removed the source ranges from before the controller took ownership are restored.
+// The given Service is never mutated and the enforcement is retried on the latest Service when it changed meanwhile.
 func (c *ConfigMapSourceRangeEnforcer) EnforceSourceRangesToService(svc *corev1.Service) error {
-	options := metav1.GetOptions{}
-	cmName := svc.ObjectMeta.Annotations[configMapAnnotationKey]
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err := c.enforce(svc)
+		if apierrors.IsConflict(err) {
+			latest, getErr := c.client.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+			if getErr != nil {
+				return getErr
+			}
+			svc = latest
+		}
+		return err
+	})
+	if apierrors.IsConflict(err) {
+		message := fmt.Sprintf("could not update Service %s: %v", svc.ObjectMeta.Name, err)
+		err = c.fail(svc, ErrorReasonTransient, err, message)
+	}
+	if err != nil {
+		c.logger.Warningf("could not enforce source ranges service=%s/%s reason=%s error=%q", svc.ObjectMeta.Namespace, svc.ObjectMeta.Name, ReasonOf(err), err)
+		c.metrics.IncEnforcementErrors(svc.ObjectMeta.Namespace, string(ReasonOf(err)))
+	}
+	return err
+}
+
+// enforce enforces loadBalancerSourceRanges to a copy of the Service, patching the changes
+func (c *ConfigMapSourceRangeEnforcer) enforce(original *corev1.Service) error {
+	svc := original.DeepCopy()
+	dryRun, err := c.isDryRun(svc)
+	if err != nil {
+		return err
+	}
 
-	if cmName != "" {
-		cm, err := c.client.CoreV1().ConfigMaps(svc.ObjectMeta.Namespace).Get(cmName, options)
+	if hasSourceRangeAnnotations(svc) {
+		sourceRanges, origins, descriptions, err := c.sourceRanges(svc)
 		if err != nil {
-			reason := "SourceRangesEnforcementFailed"
-			message := fmt.Sprintf("could not read ConfigMap %s: %v", cmName, err)
-			c.recorder.Eventf(svc, corev1.EventTypeWarning, reason, message)
 			return err
 		}
 
-		if len(difference(configMapValues(cm.Data), svc.Spec.LoadBalancerSourceRanges)) != 0 {
-			svc.Spec.LoadBalancerSourceRanges = configMapValues(cm.Data)
-			_, err = c.client.CoreV1().Services(svc.ObjectMeta.Namespace).Update(svc)
+		if c.aggregate {
+			sourceRanges = cidr.Aggregate(sourceRanges)
+		}
+
+		m, err := c.serviceMode(svc)
+		if err != nil {
+			return err
+		}
+
+		var managed []string
+		if m == modeMerge {
+			sourceRanges, managed = mergeSourceRanges(svc, sourceRanges)
+		}
+
+		if len(sourceRanges) == 0 {
+			sourceRanges, err = c.guardEmptySourceRanges(svc)
 			if err != nil {
-				reason := "SourceRangesEnforcementFailed"
-				message := fmt.Sprintf("could not update Service %s: %v", svc.ObjectMeta.Name, err)
-				c.recorder.Eventf(svc, corev1.EventTypeWarning, reason, message)
 				return err
-			} else {
+			}
+			if m == modeMerge {
+				managed = sourceRanges
+			}
+		}
+
+		rangesChanged := !cidr.NewSet(sourceRanges...).Equal(cidr.NewSet(svc.Spec.LoadBalancerSourceRanges...))
+		described := descriptions.annotation(sourceRanges)
+		if rangesChanged || managedSourceRangesChanged(svc, managed) || !isOwned(svc) || needsConfidentialMark(svc) || descriptionsChanged(svc, described) {
+			if c.maxSourceRanges > 0 && len(sourceRanges) > c.maxSourceRanges {
+				reason := "SourceRangesLimitExceeded"
+				message := fmt.Sprintf("Service %s has %d LB source ranges, more than the limit of %d", svc.ObjectMeta.Name, len(sourceRanges), c.maxSourceRanges)
+				c.recorder.Event(svc, corev1.EventTypeWarning, reason, message)
+			}
+
+			diff := newSourceRangesDiff(svc.Spec.LoadBalancerSourceRanges, sourceRanges, origins)
+			diff.confidential = isConfidentialDiff(svc, diff)
+			if dryRun {
+				c.recordDryRun(svc, diff)
+				return nil
+			}
+
+			takeOwnership(svc)
+			markConfidential(svc)
+			if rangesChanged {
+				svc.Spec.LoadBalancerSourceRanges = sourceRanges
+			}
+			setManagedSourceRanges(svc, managed)
+			setDescriptions(svc, described)
+			err = c.patchService(original, svc)
+			if apierrors.IsConflict(err) {
+				return err
+			} else if err != nil {
+				message := fmt.Sprintf("could not update Service %s: %v", svc.ObjectMeta.Name, err)
+				return c.fail(svc, ErrorReasonTransient, err, message)
+			} else if rangesChanged {
+				if isOwned(original) {
+					c.metrics.IncDriftCorrections(svc.ObjectMeta.Namespace)
+				}
+				diff.log(c.logger, "updated source ranges", svc)
 				reason := "SourceRangesEnforcementSuccessful"
-				message := fmt.Sprintf("Updated Service %s with LB source ranges: %v", svc.ObjectMeta.Name, configMapValues(cm.Data))
-				c.recorder.Eventf(svc, corev1.EventTypeNormal, reason, message)
+				message := fmt.Sprintf("Updated Service %s LB source ranges, %s", svc.ObjectMeta.Name, diff)
+				c.recorder.Event(svc, corev1.EventTypeNormal, reason, message)
 			}
 		}
+
+		c.clearDryRun(svc)
+		if !dryRun {
+			c.metrics.ObserveEnforcedService(svc.ObjectMeta.Namespace, svc.ObjectMeta.Name, len(sourceRanges), time.Now())
+		}
+	} else if isOwned(svc) {
+		return c.releaseService(original, svc, dryRun)
+	} else {
+		c.clearDryRun(svc)
 	}
 	return nil
 }
 
+// ForgetService drops the metrics and the dry run changes reported for a deleted Service
+func (c *ConfigMapSourceRangeEnforcer) ForgetService(namespace, name string) {
+	c.mu.Lock()
+	delete(c.dryRuns, namespace+"/"+name)
+	c.mu.Unlock()
+
+	c.metrics.DeleteManagedService(namespace, name)
+}
+
+// sourceRanges returns the union of the source ranges of every ConfigMap, Secret and SourceRangeSet referenced by the Service
+// along with the sources each of them comes from and their descriptions
+func (c *ConfigMapSourceRangeEnforcer) sourceRanges(svc *corev1.Service) ([]string, sourceOrigins, sourceDescriptions, error) {
+	sourceRanges := cidr.NewSet()
+	origins := sourceOrigins{}
+	descriptions := sourceDescriptions{}
+
+	for _, cmRef := range configMapNames(svc) {
+		namespace, cmName, err := splitReference(svc.ObjectMeta.Namespace, cmRef)
+		if err != nil {
+			message := fmt.Sprintf("invalid ConfigMap reference: %v", err)
+			return nil, nil, nil, c.fail(svc, ErrorReasonPermanent, err, message)
+		}
+
+		cm, err := c.configMaps.GetConfigMap(namespace, cmName)
+		if err != nil {
+			message := fmt.Sprintf("could not read ConfigMap %s: %v", cmRef, err)
+			return nil, nil, nil, c.fail(svc, readErrorReason(err), err, message)
+		}
+
+		if namespace != svc.ObjectMeta.Namespace {
+			if err := c.checkNamespaceAllowed(cm, svc.ObjectMeta.Namespace); err != nil {
+				message := fmt.Sprintf("could not use ConfigMap %s: %v", cmRef, err)
+				return nil, nil, nil, c.fail(svc, ReasonOf(err), err, message)
+			}
+		}
+
+		values, err := configMapValues(cm.Data, time.Now())
+		if err != nil {
+			message := fmt.Sprintf("invalid source ranges document in ConfigMap %s %v", cmRef, err)
+			return nil, nil, nil, c.fail(svc, ErrorReasonPermanent, err, message)
+		}
+		if len(values.invalidKeys) != 0 {
+			reason := "SourceRangesValidationFailed"
+			message := fmt.Sprintf("ignored invalid source ranges from ConfigMap %s keys: %s", cmRef, strings.Join(values.invalidKeys, ", "))
+			c.recorder.Event(svc, corev1.EventTypeWarning, reason, message)
+			c.metrics.AddValidationRejections(svc.ObjectMeta.Namespace, "ConfigMap", values.rejected)
+		}
+		for _, value := range values.valid {
+			sourceRanges.Insert(value.value)
+			origins.add(value.value, describeOrigin(fmt.Sprintf("ConfigMap %s key %s", cmRef, value.origin), value.description))
+			descriptions.add(value.value, value.description)
+		}
+	}
+
+	secretValues, err := c.secretValues(svc)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	setValues, err := c.sourceRangeSetValues(svc)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, value := range append(secretValues, setValues...) {
+		sourceRanges.Insert(value.value)
+		origins.add(value.value, value.origin)
+		descriptions.add(value.value, value.description)
+	}
+
+	return sourceRanges.List(), origins, descriptions, nil
+}
+
 // NewConfigMapSourceRangeEnforcer returns a new ConfigMapSourceRangeEnforcer
 func NewConfigMapSourceRangeEnforcer(k8sCli kubernetes.Interface, recorder record.EventRecorder) SourceRangeEnforcer {
+	return NewConfigMapSourceRangeEnforcerWithConfig(Config{}, k8sCli, recorder)
+}
+
+// NewConfigMapSourceRangeEnforcerWithConfig returns a new ConfigMapSourceRangeEnforcer using the given configuration
+func NewConfigMapSourceRangeEnforcerWithConfig(cfg Config, k8sCli kubernetes.Interface, recorder record.EventRecorder) SourceRangeEnforcer {
+	configMaps := cfg.ConfigMapGetter
+	if configMaps == nil {
+		configMaps = &clientConfigMapGetter{client: k8sCli}
+	}
+
+	secrets := cfg.SecretGetter
+	if secrets == nil {
+		secrets = &clientSecretGetter{client: k8sCli}
+	}
+
+	emptyPolicy := cfg.EmptySourceRangesPolicy
+	if emptyPolicy == "" {
+		emptyPolicy = EmptySourceRangesKeep
+	}
+
+	logger := cfg.Logger
+	if logger == nil {
+		logger = log.Dummy
+	}
+
+	metricsRecorder := cfg.MetricsRecorder
+	if metricsRecorder == nil {
+		metricsRecorder = metrics.Dummy
+	}
+
 	return &ConfigMapSourceRangeEnforcer{
-		client:   k8sCli,
-		recorder: recorder,
+		client:          k8sCli,
+		configMaps:      configMaps,
+		sourceRangeSets: cfg.SourceRangeSetGetter,
+		secrets:         secrets,
+		recorder:        recorder,
+		aggregate:       cfg.Aggregate,
+		maxSourceRanges: cfg.MaxSourceRanges,
+		emptyPolicy:     emptyPolicy,
+		metrics:         metricsRecorder,
+		dryRun:          cfg.DryRun,
+		logger:          logger,
+		dryRuns:         map[string]string{},
+	}
+}
+
+// clientConfigMapGetter gets ConfigMaps straight from the API server
+type clientConfigMapGetter struct {
+	client kubernetes.Interface
+}
+
+func (c *clientConfigMapGetter) GetConfigMap(namespace, name string) (*corev1.ConfigMap, error) {
+	return c.client.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+}
+
+// ConfigMapReferences returns the namespace/name keys of the ConfigMaps referenced by the Service annotation
+func ConfigMapReferences(svc *corev1.Service) []string {
+	var refs []string
+	for _, cmRef := range configMapNames(svc) {
+		if namespace, cmName, err := splitReference(svc.ObjectMeta.Namespace, cmRef); err == nil {
+			refs = append(refs, namespace+"/"+cmName)
+		}
 	}
+	return refs
 }
 
-func configMapValues(data map[string]string) []string {
-	values := make([]string, 0, len(data))
-	for value := range data {
-		values = append(values, data[value])
+// hasSourceRangeAnnotations tells if the Service references any ConfigMap, Secret or SourceRangeSet
+func hasSourceRangeAnnotations(svc *corev1.Service) bool {
+	return svc.ObjectMeta.Annotations[configMapAnnotationKey] != "" ||
+		svc.ObjectMeta.Annotations[secretAnnotationKey] != "" ||
+		svc.ObjectMeta.Annotations[sourceRangeSetAnnotationKey] != ""
+}
+
+// configMapNames returns the de-duplicated ConfigMap names from the comma-separated annotation
+func configMapNames(svc *corev1.Service) []string {
+	return splitAnnotation(svc.ObjectMeta.Annotations[configMapAnnotationKey])
+}
+
+// splitAnnotation returns the de-duplicated non-empty values of a comma-separated annotation
+func splitAnnotation(annotation string) []string {
+	var names []string
+	seen := map[string]bool{}
+
+	for _, name := range strings.Split(annotation, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
 	}
-	return values
+	return names
 }
 
-func difference(slice1 []string, slice2 []string) []string {
-	var diff []string
+// configMapSourceRanges are the source ranges of a ConfigMap
+type configMapSourceRanges struct {
+	// valid are the canonical source ranges along with their keys and descriptions
+	valid []sourceRange
+	// invalidKeys are the keys holding invalid source ranges
+	invalidKeys []string
+	// rejected is the number of invalid source ranges left out
+	rejected int
+}
 
-	// Loop two times, first to find slice1 strings not in slice2,
-	// second loop to find slice2 strings not in slice1
-	for i := 0; i < 2; i++ {
-		for _, s1 := range slice1 {
-			found := false
-			for _, s2 := range slice2 {
-				if s1 == s2 {
-					found = true
-					break
-				}
+// configMapValues returns the source ranges of the ConfigMap data, either flat values or YAML and JSON documents
+// for the keys with those extensions. Disabled keys are skipped and an error is returned when any document doesn't
+// match the schema.
+func configMapValues(data map[string]string, now time.Time) (configMapSourceRanges, error) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		if !strings.HasSuffix(key, disabledKeySuffix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	values := configMapSourceRanges{valid: make([]sourceRange, 0, len(data))}
+	for _, key := range keys {
+		entries := valueEntries(data[key])
+		if isDocumentKey(key) {
+			var err error
+			if entries, err = documentEntries(data[key], now); err != nil {
+				return configMapSourceRanges{}, fmt.Errorf("key %s: %v", key, err)
 			}
-			// String not found. We add it to return slice
-			if !found {
-				diff = append(diff, s1)
+		}
+
+		invalid := false
+		for _, entry := range entries {
+			value, err := cidr.Canonical(entry.value)
+			if err != nil {
+				invalid = true
+				values.rejected++
+				continue
 			}
+			values.valid = append(values.valid, sourceRange{value: value, origin: key, description: entry.description})
 		}
-		// Swap the slices, only if it was the first loop
-		if i == 0 {
-			slice1, slice2 = slice2, slice1
+		if invalid {
+			values.invalidKeys = append(values.invalidKeys, key)
 		}
 	}
+	return values, nil
+}
 
-	return diff
+// valueEntries returns the source ranges of a ConfigMap value, separated by newlines or commas. Anything
+// following a # is a comment, which describes the source ranges of its line.
+func valueEntries(value string) []sourceRange {
+	var entries []sourceRange
+	for _, line := range strings.Split(value, "\n") {
+		description := ""
+		if i := strings.Index(line, "#"); i >= 0 {
+			line, description = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		for _, entry := range strings.Split(line, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				entries = append(entries, sourceRange{value: entry, description: description})
+			}
+		}
+	}
+	return entries
 }
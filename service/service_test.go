@@ -1,6 +1,7 @@
 package service_test
 
 import (
+	"encoding/json"
 	"errors"
 	"testing"
 
@@ -10,13 +11,15 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
 	kubetesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/record"
 )
 
 func TestEnforceSourceRangesToServiceWithNoRanges(t *testing.T) {
-	k8sCli := fake.NewSimpleClientset()
+	k8sCli := newFakeClientset()
 
 	cm := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
@@ -53,7 +56,7 @@ func TestEnforceSourceRangesToServiceWithNoRanges(t *testing.T) {
 }
 
 func TestEnforceSourceRangesToServiceWithLessRanges(t *testing.T) {
-	k8sCli := fake.NewSimpleClientset()
+	k8sCli := newFakeClientset()
 
 	cm := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
@@ -94,7 +97,7 @@ func TestEnforceSourceRangesToServiceWithLessRanges(t *testing.T) {
 }
 
 func TestEnforceSourceRangesToServiceWithMoreRanges(t *testing.T) {
-	k8sCli := fake.NewSimpleClientset()
+	k8sCli := newFakeClientset()
 
 	cm := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
@@ -134,7 +137,7 @@ func TestEnforceSourceRangesToServiceWithMoreRanges(t *testing.T) {
 }
 
 func TestEnforceSourceRangesToServiceWithNoChanges(t *testing.T) {
-	k8sCli := fake.NewSimpleClientset()
+	k8sCli := newFakeClientset()
 
 	cm := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
@@ -174,8 +177,50 @@ func TestEnforceSourceRangesToServiceWithNoChanges(t *testing.T) {
 	}
 }
 
+func TestEnforceSourceRangesToServiceWithEquivalentRanges(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-config",
+		},
+		Data: map[string]string{
+			"test":  "123.123.123.123/32",
+			"test2": "10.0.0.0/8",
+			"test3": "10.0.0.0/8",
+		},
+	}
+	k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Create(cm)
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/config-map": "test-config",
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			LoadBalancerSourceRanges: []string{"10.0.0.0/8", "123.123.123.123"},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	recorder := record.NewFakeRecorder(1)
+	e := service.NewConfigMapSourceRangeEnforcer(k8sCli, recorder)
+
+	e.EnforceSourceRangesToService(svc)
+
+	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	assert.Equal(t, []string{"10.0.0.0/8", "123.123.123.123"}, new.Spec.LoadBalancerSourceRanges)
+
+	events := collectEvents(recorder.Events)
+	if eventCount := len(events); eventCount != 0 {
+		t.Errorf("Expected 0 event when service load balancer source ranges are equivalent but got %d", eventCount)
+	}
+}
+
 func TestEnforceSourceRangesToServiceWithNonExistingConfigMap(t *testing.T) {
-	k8sCli := fake.NewSimpleClientset()
+	k8sCli := newFakeClientset()
 
 	svc := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
@@ -192,6 +237,7 @@ func TestEnforceSourceRangesToServiceWithNonExistingConfigMap(t *testing.T) {
 
 	err := e.EnforceSourceRangesToService(svc)
 	assert.NotNil(t, err)
+	assert.Equal(t, service.ErrorReasonPermanent, service.ReasonOf(err))
 
 	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
 	assert.Nil(t, new.Spec.LoadBalancerSourceRanges)
@@ -201,13 +247,13 @@ func TestEnforceSourceRangesToServiceWithNonExistingConfigMap(t *testing.T) {
 		t.Errorf("Expected 1 event when can't find ConfigMap specified by annotation but got %d", eventCount)
 	}
 
-	assert.Equal(t, "Warning SourceRangesEnforcementFailed could not read ConfigMap test-config: configmaps \"test-config\" not found", events[0])
+	assert.Equal(t, "Warning SourceRangesEnforcementFailed could not read ConfigMap test-config: configmaps \"test-config\" not found (reason: permanent)", events[0])
 }
 
 func TestEnforceSourceRangesToServiceWhenError(t *testing.T) {
 	k8sCli := &fake.Clientset{}
 
-	k8sCli.AddReactor("update", "services", func(action kubetesting.Action) (bool, runtime.Object, error) {
+	k8sCli.AddReactor("patch", "services", func(action kubetesting.Action) (bool, runtime.Object, error) {
 		return true, nil, apierrors.NewInternalError(errors.New("API server down"))
 	})
 
@@ -239,6 +285,7 @@ func TestEnforceSourceRangesToServiceWhenError(t *testing.T) {
 
 	err := e.EnforceSourceRangesToService(svc)
 	assert.NotNil(t, err)
+	assert.Equal(t, service.ErrorReasonTransient, service.ReasonOf(err))
 
 	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
 	assert.Nil(t, new.Spec.LoadBalancerSourceRanges)
@@ -249,11 +296,11 @@ func TestEnforceSourceRangesToServiceWhenError(t *testing.T) {
 		return
 	}
 
-	assert.Equal(t, "Warning SourceRangesEnforcementFailed could not update Service test-service: Internal error occurred: API server down", events[0])
+	assert.Equal(t, "Warning SourceRangesEnforcementFailed could not update Service test-service: Internal error occurred: API server down (reason: transient)", events[0])
 }
 
 func TestEnforceSourceRangesToServiceWithoutAnnotation(t *testing.T) {
-	k8sCli := fake.NewSimpleClientset()
+	k8sCli := newFakeClientset()
 
 	svc := corev1.Service{
 		Spec: corev1.ServiceSpec{
@@ -271,6 +318,434 @@ func TestEnforceSourceRangesToServiceWithoutAnnotation(t *testing.T) {
 	assert.Equal(t, []string{"123.123.123.122/32"}, new.Spec.LoadBalancerSourceRanges)
 }
 
+func TestEnforceSourceRangesToServiceWithMultipleConfigMaps(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	offices := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "corporate-offices",
+		},
+		Data: map[string]string{
+			"lisbon": "123.123.123.123/32",
+			"berlin": "123.123.123.124/32",
+		},
+	}
+	k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Create(offices)
+
+	vendors := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "vendors",
+		},
+		Data: map[string]string{
+			"acme":   "123.123.123.125/32",
+			"lisbon": "123.123.123.123/32",
+		},
+	}
+	k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Create(vendors)
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/config-map": "corporate-offices, vendors",
+			},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	recorder := record.NewFakeRecorder(1)
+	e := service.NewConfigMapSourceRangeEnforcer(k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.Nil(t, err)
+
+	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	assert.ElementsMatch(t, []string{"123.123.123.123/32", "123.123.123.124/32", "123.123.123.125/32"}, new.Spec.LoadBalancerSourceRanges)
+}
+
+func TestEnforceSourceRangesToServiceWithOneNonExistingConfigMap(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "corporate-offices",
+		},
+		Data: map[string]string{
+			"lisbon": "123.123.123.123/32",
+		},
+	}
+	k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Create(cm)
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/config-map": "corporate-offices,vendors",
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			LoadBalancerSourceRanges: []string{"123.123.123.122/32"},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	recorder := record.NewFakeRecorder(1)
+	e := service.NewConfigMapSourceRangeEnforcer(k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.NotNil(t, err)
+
+	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	assert.Equal(t, []string{"123.123.123.122/32"}, new.Spec.LoadBalancerSourceRanges)
+
+	events := collectEvents(recorder.Events)
+	if eventCount := len(events); eventCount != 1 {
+		t.Errorf("Expected 1 event when can't find one of the ConfigMaps specified by annotation but got %d", eventCount)
+		return
+	}
+
+	assert.Equal(t, "Warning SourceRangesEnforcementFailed could not read ConfigMap vendors: configmaps \"vendors\" not found (reason: permanent)", events[0])
+}
+
+func TestEnforceSourceRangesToServiceWithInvalidRanges(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-config",
+		},
+		Data: map[string]string{
+			"test":    " 123.123.123.123 ",
+			"test2":   "10.1.2.3/8",
+			"typo":    "10.0.0.300/24",
+			"garbage": "office",
+		},
+	}
+	k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Create(cm)
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/config-map": "test-config",
+			},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	recorder := record.NewFakeRecorder(2)
+	e := service.NewConfigMapSourceRangeEnforcer(k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.Nil(t, err)
+
+	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	assert.ElementsMatch(t, []string{"123.123.123.123/32", "10.0.0.0/8"}, new.Spec.LoadBalancerSourceRanges)
+
+	events := collectEvents(recorder.Events)
+	if eventCount := len(events); eventCount != 2 {
+		t.Errorf("Expected 2 events when ConfigMap has invalid source ranges but got %d", eventCount)
+		return
+	}
+
+	assert.Equal(t, "Warning SourceRangesValidationFailed ignored invalid source ranges from ConfigMap test-config keys: garbage, typo", events[0])
+}
+
+func TestEnforceSourceRangesToServiceWithMultiValueEntries(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-config",
+		},
+		Data: map[string]string{
+			"offices":         "203.0.113.0/24  # Lisbon office\n# Porto is moving\n198.51.100.0/24, 198.51.101.0/24 # Porto\n",
+			"vendors":         "192.0.2.1,not-a-cidr, 192.0.2.300",
+			"legacy.disabled": "10.0.0.0/8",
+		},
+	}
+	k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Create(cm)
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Name:      "test-service",
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/config-map": "test-config",
+			},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	recorder := record.NewFakeRecorder(2)
+	metrics := &fakeMetricsRecorder{}
+	e := service.NewConfigMapSourceRangeEnforcerWithConfig(service.Config{MetricsRecorder: metrics}, k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.NoError(t, err)
+
+	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	assert.ElementsMatch(t, []string{"192.0.2.1/32", "198.51.100.0/24", "198.51.101.0/24", "203.0.113.0/24"}, new.Spec.LoadBalancerSourceRanges)
+
+	events := collectEvents(recorder.Events)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "Warning SourceRangesValidationFailed ignored invalid source ranges from ConfigMap test-config keys: vendors", events[0])
+		assert.Contains(t, events[1], `203.0.113.0/24 (ConfigMap test-config key offices "Lisbon office")`)
+		assert.Contains(t, events[1], `198.51.101.0/24 (ConfigMap test-config key offices "Porto")`)
+		assert.Contains(t, events[1], "192.0.2.1/32 (ConfigMap test-config key vendors)")
+	}
+	assert.Equal(t, 2, metrics.validationRejections["default/ConfigMap"])
+}
+
+func TestEnforceSourceRangesToServiceRecordsMetrics(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-config",
+		},
+		Data: map[string]string{
+			"test": "123.123.123.123/32",
+			"typo": "10.0.0.300/24",
+		},
+	}
+	k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Create(cm)
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Name:      "test-service",
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/config-map":             "test-config",
+				"source-ranges.alpha.girao.net/original-source-ranges": "",
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	m := &fakeMetricsRecorder{}
+	e := service.NewConfigMapSourceRangeEnforcerWithConfig(service.Config{MetricsRecorder: m}, k8sCli, record.NewFakeRecorder(3))
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[string]int{"default/test-service": 1}, m.enforcedServices)
+	assert.Equal(t, map[string]int{"default": 1}, m.driftCorrections)
+	assert.Equal(t, map[string]int{"default/ConfigMap": 1}, m.validationRejections)
+
+	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	delete(new.ObjectMeta.Annotations, "source-ranges.alpha.girao.net/config-map")
+	new, _ = k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Update(new)
+
+	err = e.EnforceSourceRangesToService(new)
+	assert.Nil(t, err)
+
+	assert.Empty(t, m.enforcedServices)
+}
+
+func TestEnforceSourceRangesToServiceWithAggregation(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-config",
+		},
+		Data: map[string]string{
+			"test":  "123.123.123.122/32",
+			"test2": "123.123.123.123/32",
+			"test3": "10.0.0.0/24",
+			"test4": "10.0.0.0/8",
+		},
+	}
+	k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Create(cm)
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Name:      "test-service",
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/config-map": "test-config",
+			},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	recorder := record.NewFakeRecorder(2)
+	e := service.NewConfigMapSourceRangeEnforcerWithConfig(service.Config{Aggregate: true, MaxSourceRanges: 1}, k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.Nil(t, err)
+
+	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	assert.Equal(t, []string{"10.0.0.0/8", "123.123.123.122/31"}, new.Spec.LoadBalancerSourceRanges)
+
+	events := collectEvents(recorder.Events)
+	if eventCount := len(events); eventCount != 2 {
+		t.Errorf("Expected 2 events when service load balancer source ranges exceed the limit but got %d", eventCount)
+		return
+	}
+
+	assert.Equal(t, "Warning SourceRangesLimitExceeded Service test-service has 2 LB source ranges, more than the limit of 1", events[0])
+}
+
+type configMapGetterFunc func(namespace, name string) (*corev1.ConfigMap, error)
+
+func (f configMapGetterFunc) GetConfigMap(namespace, name string) (*corev1.ConfigMap, error) {
+	return f(namespace, name)
+}
+
+func TestEnforceSourceRangesToServiceWithConfigMapGetter(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/config-map": "test-config",
+			},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	getter := configMapGetterFunc(func(namespace, name string) (*corev1.ConfigMap, error) {
+		assert.Equal(t, metav1.NamespaceDefault, namespace)
+		assert.Equal(t, "test-config", name)
+		return &corev1.ConfigMap{
+			Data: map[string]string{
+				"test": "123.123.123.123/32",
+			},
+		}, nil
+	})
+
+	recorder := record.NewFakeRecorder(1)
+	e := service.NewConfigMapSourceRangeEnforcerWithConfig(service.Config{ConfigMapGetter: getter}, k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.Nil(t, err)
+
+	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	assert.Equal(t, []string{"123.123.123.123/32"}, new.Spec.LoadBalancerSourceRanges)
+}
+
+func TestConfigMapReferences(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team-a",
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/config-map": "whitelist",
+			},
+		},
+	}
+	assert.Equal(t, []string{"team-a/whitelist"}, service.ConfigMapReferences(svc))
+
+	svc.ObjectMeta.Annotations["source-ranges.alpha.girao.net/config-map"] = "offices, vendors,,offices"
+	assert.Equal(t, []string{"team-a/offices", "team-a/vendors"}, service.ConfigMapReferences(svc))
+	assert.Empty(t, service.ConfigMapReferences(&corev1.Service{}))
+}
+
+// enforceFixture is a Service along with the objects it references, to enforce source ranges to
+type enforceFixture struct {
+	// config is the enforcer configuration
+	config service.Config
+	// data is the data of the default/test-config ConfigMap, which is only created when not nil
+	data map[string]string
+	// objects are created before the Service, like Namespaces and ConfigMaps from other namespaces
+	objects []runtime.Object
+	// namespace is the namespace of the test-service Service, default when empty
+	namespace    string
+	annotations  map[string]string
+	sourceRanges []string
+}
+
+// enforce creates the objects and the Service and enforces source ranges to it, returning the resulting Service and the events
+func (f enforceFixture) enforce() (*corev1.Service, []string, error) {
+	k8sCli := newFakeClientset(f.objects...)
+
+	if f.data != nil {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: metav1.NamespaceDefault,
+				Name:      "test-config",
+			},
+			Data: f.data,
+		}
+		k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Create(cm)
+	}
+
+	namespace := f.namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   namespace,
+			Name:        "test-service",
+			Annotations: f.annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			LoadBalancerSourceRanges: f.sourceRanges,
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	recorder := record.NewFakeRecorder(10)
+	e := service.NewConfigMapSourceRangeEnforcerWithConfig(f.config, k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+
+	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	return new, collectEvents(recorder.Events), err
+}
+
+// withConfigMap returns the annotations along with the one referencing the test-config ConfigMap
+func withConfigMap(annotations map[string]string) map[string]string {
+	svcAnnotations := map[string]string{
+		"source-ranges.alpha.girao.net/config-map": "test-config",
+	}
+	for k, v := range annotations {
+		svcAnnotations[k] = v
+	}
+	return svcAnnotations
+}
+
+// newFakeClientset returns a fake clientset holding the objects that also applies merge patches
+// to Services, which the fake object tracker doesn't support
+func newFakeClientset(objects ...runtime.Object) *fake.Clientset {
+	tracker := kubetesting.NewObjectTracker(scheme.Scheme, scheme.Codecs.UniversalDecoder())
+	for _, obj := range objects {
+		tracker.Add(obj)
+	}
+
+	k8sCli := &fake.Clientset{}
+	k8sCli.AddReactor("patch", "services", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		patch := action.(kubetesting.PatchAction)
+		obj, err := tracker.Get(action.GetResource(), action.GetNamespace(), patch.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+
+		original, err := json.Marshal(obj)
+		if err != nil {
+			return true, nil, err
+		}
+		patched, err := strategicpatch.StrategicMergePatch(original, patch.GetPatch(), corev1.Service{})
+		if err != nil {
+			return true, nil, err
+		}
+
+		svc := &corev1.Service{}
+		if err := json.Unmarshal(patched, svc); err != nil {
+			return true, nil, err
+		}
+		return true, svc, tracker.Update(action.GetResource(), svc, action.GetNamespace())
+	})
+	k8sCli.AddReactor("*", "*", kubetesting.ObjectReaction(tracker))
+	return k8sCli
+}
+
 func collectEvents(source <-chan string) []string {
 	done := false
 	events := make([]string, 0)
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jeffersongirao/source-ranges-controller/apis/sourceranges/v1alpha1"
+	"github.com/jeffersongirao/source-ranges-controller/cidr"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// The annotation used for figuring out SourceRangeSets to get loadBalancerSourceRanges from,
+	// ClusterSourceRangeSets are referenced with the clustersourcerangeset/ prefix
+	sourceRangeSetAnnotationKey = "source-ranges.alpha.girao.net/source-range-sets"
+
+	clusterSourceRangeSetPrefix = "clustersourcerangeset/"
+)
+
+// SourceRangeSetGetter gets the SourceRangeSets and ClusterSourceRangeSets holding loadBalancerSourceRanges
+type SourceRangeSetGetter interface {
+	GetSourceRangeSet(namespace, name string) (*v1alpha1.SourceRangeSet, error)
+	GetClusterSourceRangeSet(name string) (*v1alpha1.ClusterSourceRangeSet, error)
+}
+
+// SourceRangeSetReferences returns the keys of the SourceRangeSets and ClusterSourceRangeSets referenced by the Service annotation
+func SourceRangeSetReferences(svc *corev1.Service) (sets []string, clusterSets []string) {
+	setNames, clusterSetNames := sourceRangeSetNames(svc)
+	for _, name := range setNames {
+		sets = append(sets, svc.ObjectMeta.Namespace+"/"+name)
+	}
+	return sets, clusterSetNames
+}
+
+// sourceRangeSetValues returns the valid source ranges of every set referenced by the Service
+func (c *ConfigMapSourceRangeEnforcer) sourceRangeSetValues(svc *corev1.Service) ([]sourceRange, error) {
+	setNames, clusterSetNames := sourceRangeSetNames(svc)
+	if len(setNames) == 0 && len(clusterSetNames) == 0 {
+		return nil, nil
+	}
+
+	if c.sourceRangeSets == nil {
+		err := fmt.Errorf("SourceRangeSets are not enabled in the controller")
+		message := fmt.Sprintf("could not read SourceRangeSets: %v", err)
+		return nil, c.fail(svc, ErrorReasonPermanent, err, message)
+	}
+
+	var values []sourceRange
+	for _, name := range setNames {
+		set, err := c.sourceRangeSets.GetSourceRangeSet(svc.ObjectMeta.Namespace, name)
+		if err != nil {
+			message := fmt.Sprintf("could not read SourceRangeSet %s: %v", name, err)
+			return nil, c.fail(svc, readErrorReason(err), err, message)
+		}
+		valid := validSourceRanges(set.Spec)
+		if rejected := len(set.Spec.SourceRanges) - len(valid); rejected != 0 {
+			c.metrics.AddValidationRejections(svc.ObjectMeta.Namespace, "SourceRangeSet", rejected)
+		}
+		for _, value := range valid {
+			values = append(values, sourceRange{value: value.value, origin: describeOrigin("SourceRangeSet "+name, value.description), description: value.description})
+		}
+	}
+
+	for _, name := range clusterSetNames {
+		set, err := c.sourceRangeSets.GetClusterSourceRangeSet(name)
+		if err != nil {
+			message := fmt.Sprintf("could not read ClusterSourceRangeSet %s: %v", name, err)
+			return nil, c.fail(svc, readErrorReason(err), err, message)
+		}
+		valid := validSourceRanges(set.Spec)
+		if rejected := len(set.Spec.SourceRanges) - len(valid); rejected != 0 {
+			c.metrics.AddValidationRejections(svc.ObjectMeta.Namespace, "ClusterSourceRangeSet", rejected)
+		}
+		for _, value := range valid {
+			values = append(values, sourceRange{value: value.value, origin: describeOrigin("ClusterSourceRangeSet "+name, value.description), description: value.description})
+		}
+	}
+
+	return values, nil
+}
+
+// sourceRangeSetNames returns the de-duplicated SourceRangeSet and ClusterSourceRangeSet names from the annotation
+func sourceRangeSetNames(svc *corev1.Service) (sets []string, clusterSets []string) {
+	for _, name := range splitAnnotation(svc.ObjectMeta.Annotations[sourceRangeSetAnnotationKey]) {
+		if strings.HasPrefix(strings.ToLower(name), clusterSourceRangeSetPrefix) {
+			clusterSets = append(clusterSets, name[len(clusterSourceRangeSetPrefix):])
+		} else {
+			sets = append(sets, name)
+		}
+	}
+	return sets, clusterSets
+}
+
+// validSourceRanges returns the canonical CIDRs of the set along with their descriptions, invalid ones
+// are reported in the set status instead
+func validSourceRanges(spec v1alpha1.SourceRangeSetSpec) []sourceRange {
+	values := make([]sourceRange, 0, len(spec.SourceRanges))
+	for _, r := range spec.SourceRanges {
+		if value, err := cidr.Canonical(r.CIDR); err == nil {
+			values = append(values, sourceRange{value: value, description: r.Description})
+		}
+	}
+	return values
+}
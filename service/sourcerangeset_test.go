@@ -0,0 +1,160 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/jeffersongirao/source-ranges-controller/apis/sourceranges/v1alpha1"
+	"github.com/jeffersongirao/source-ranges-controller/service"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+type fakeSourceRangeSetGetter struct {
+	sets        map[string]*v1alpha1.SourceRangeSet
+	clusterSets map[string]*v1alpha1.ClusterSourceRangeSet
+}
+
+func (f *fakeSourceRangeSetGetter) GetSourceRangeSet(namespace, name string) (*v1alpha1.SourceRangeSet, error) {
+	if set, ok := f.sets[namespace+"/"+name]; ok {
+		return set, nil
+	}
+	return nil, apierrors.NewNotFound(v1alpha1.Resource("sourcerangesets"), name)
+}
+
+func (f *fakeSourceRangeSetGetter) GetClusterSourceRangeSet(name string) (*v1alpha1.ClusterSourceRangeSet, error) {
+	if set, ok := f.clusterSets[name]; ok {
+		return set, nil
+	}
+	return nil, apierrors.NewNotFound(v1alpha1.Resource("clustersourcerangesets"), name)
+}
+
+func TestEnforceSourceRangesToServiceWithSourceRangeSets(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-config",
+		},
+		Data: map[string]string{
+			"test": "123.123.123.123/32",
+		},
+	}
+	k8sCli.CoreV1().ConfigMaps(metav1.NamespaceDefault).Create(cm)
+
+	getter := &fakeSourceRangeSetGetter{
+		sets: map[string]*v1alpha1.SourceRangeSet{
+			"default/vendors": {
+				Spec: v1alpha1.SourceRangeSetSpec{
+					SourceRanges: []v1alpha1.SourceRange{
+						{CIDR: "123.123.123.124/32", Description: "acme"},
+						{CIDR: "not-a-cidr"},
+					},
+				},
+			},
+		},
+		clusterSets: map[string]*v1alpha1.ClusterSourceRangeSet{
+			"corporate-offices": {
+				Spec: v1alpha1.SourceRangeSetSpec{
+					SourceRanges: []v1alpha1.SourceRange{
+						{CIDR: "123.123.123.123/32", Description: "lisbon office"},
+						{CIDR: "123.123.123.125/32", Description: "berlin office"},
+					},
+				},
+			},
+		},
+	}
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/config-map":        "test-config",
+				"source-ranges.alpha.girao.net/source-range-sets": "vendors,clustersourcerangeset/corporate-offices",
+			},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	recorder := record.NewFakeRecorder(1)
+	e := service.NewConfigMapSourceRangeEnforcerWithConfig(service.Config{SourceRangeSetGetter: getter}, k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.Nil(t, err)
+
+	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	assert.ElementsMatch(t, []string{"123.123.123.123/32", "123.123.123.124/32", "123.123.123.125/32"}, new.Spec.LoadBalancerSourceRanges)
+}
+
+func TestEnforceSourceRangesToServiceWithNonExistingSourceRangeSet(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/source-range-sets": "clustersourcerangeset/corporate-offices",
+			},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	recorder := record.NewFakeRecorder(1)
+	e := service.NewConfigMapSourceRangeEnforcerWithConfig(service.Config{SourceRangeSetGetter: &fakeSourceRangeSetGetter{}}, k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.NotNil(t, err)
+
+	new, _ := k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Get(svc.ObjectMeta.Name, metav1.GetOptions{})
+	assert.Nil(t, new.Spec.LoadBalancerSourceRanges)
+
+	events := collectEvents(recorder.Events)
+	if eventCount := len(events); eventCount != 1 {
+		t.Errorf("Expected 1 event when can't find SourceRangeSet specified by annotation but got %d", eventCount)
+		return
+	}
+
+	assert.Equal(t, "Warning SourceRangesEnforcementFailed could not read ClusterSourceRangeSet corporate-offices: clustersourcerangesets.sourceranges.girao.net \"corporate-offices\" not found (reason: permanent)", events[0])
+}
+
+func TestEnforceSourceRangesToServiceWithSourceRangeSetsDisabled(t *testing.T) {
+	k8sCli := newFakeClientset()
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/source-range-sets": "vendors",
+			},
+		},
+	}
+	k8sCli.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+
+	recorder := record.NewFakeRecorder(1)
+	e := service.NewConfigMapSourceRangeEnforcer(k8sCli, recorder)
+
+	err := e.EnforceSourceRangesToService(svc)
+	assert.NotNil(t, err)
+
+	events := collectEvents(recorder.Events)
+	if eventCount := len(events); eventCount != 1 {
+		t.Errorf("Expected 1 event when SourceRangeSets are not enabled but got %d", eventCount)
+	}
+}
+
+func TestSourceRangeSetReferences(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team-a",
+			Annotations: map[string]string{
+				"source-ranges.alpha.girao.net/source-range-sets": "vendors, clustersourcerangeset/corporate-offices",
+			},
+		},
+	}
+
+	sets, clusterSets := service.SourceRangeSetReferences(svc)
+	assert.Equal(t, []string{"team-a/vendors"}, sets)
+	assert.Equal(t, []string{"corporate-offices"}, clusterSets)
+}